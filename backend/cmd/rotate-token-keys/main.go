@@ -0,0 +1,123 @@
+// Command rotate-token-keys re-encrypts stored provider tokens with the active key
+//
+// Run it after adding a new key to TOKEN_ENCRYPTION_KEYS and pointing TOKEN_ENCRYPTION_KEY_ID at it:
+//
+//	go run ./cmd/rotate-token-keys
+//
+// Tokens still stored in plaintext are encrypted as well. Once it reports nothing left to
+// rotate the old key can be removed from TOKEN_ENCRYPTION_KEYS.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/secrets"
+	"github.com/jackc/pgx/v5"
+	"github.com/joho/godotenv"
+)
+
+// Every column that holds an encrypted provider token, keyed by the row's primary key
+var tokenColumns = []struct {
+	table  string
+	column string
+	key    string
+}{
+	{"github_integrations", "access_token", "user_id"},
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only count the tokens that would be re-encrypted")
+	flag.Parse()
+
+	godotenv.Load()
+	db.Connect()
+	secrets.Init()
+	if secrets.ActiveKeyID() == "" {
+		log.Fatal("TOKEN_ENCRYPTION_KEYS must be set to rotate tokens")
+	}
+
+	failed := false
+	for _, tc := range tokenColumns {
+		rotated, skipped, err := rotate(tc.table, tc.column, tc.key, *dryRun)
+		if err != nil {
+			log.Printf("%s.%s: %v", tc.table, tc.column, err)
+			failed = true
+			continue
+		}
+		verb := "re-encrypted"
+		if *dryRun {
+			verb = "would re-encrypt"
+		}
+		log.Printf("%s.%s: %s %d tokens, %d could not be decrypted", tc.table, tc.column, verb, rotated, skipped)
+		if skipped > 0 {
+			failed = true
+		}
+	}
+	if failed {
+		log.Fatal("Some tokens were not rotated, keep the old keys configured until they are")
+	}
+}
+
+// Helper function to re-encrypt every token in one column that isn't sealed with the active key
+// Each row is updated only if it hasn't changed since it was read, so a member reconnecting
+// during the rotation keeps their new token
+func rotate(table, column, key string, dryRun bool) (rotated, skipped int, err error) {
+	ctx := context.Background()
+	query := fmt.Sprintf(`SELECT %s, %s FROM %s WHERE %s <> ''`,
+		pgx.Identifier{key}.Sanitize(), pgx.Identifier{column}.Sanitize(),
+		pgx.Identifier{table}.Sanitize(), pgx.Identifier{column}.Sanitize())
+	update := fmt.Sprintf(`UPDATE %s SET %s = $1 WHERE %s = $2 AND %s = $3`,
+		pgx.Identifier{table}.Sanitize(), pgx.Identifier{column}.Sanitize(),
+		pgx.Identifier{key}.Sanitize(), pgx.Identifier{column}.Sanitize())
+
+	rows, err := db.Pool.Query(ctx, query)
+	if err != nil {
+		return 0, 0, err
+	}
+	type row struct {
+		id     int
+		stored string
+	}
+	pending := []row{}
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.stored); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		if secrets.NeedsRotation(r.stored) {
+			pending = append(pending, r)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	for _, r := range pending {
+		token, err := secrets.Decrypt(r.stored)
+		if err != nil {
+			// Never print the stored value, only which row and key it was
+			log.Printf("%s %d: can't decrypt token sealed with key %q: %v", table, r.id, secrets.KeyID(r.stored), err)
+			skipped++
+			continue
+		}
+		if dryRun {
+			rotated++
+			continue
+		}
+		encrypted, err := secrets.Encrypt(token)
+		if err != nil {
+			return rotated, skipped, err
+		}
+		if _, err := db.Pool.Exec(ctx, update, encrypted, r.id, r.stored); err != nil {
+			return rotated, skipped, err
+		}
+		rotated++
+	}
+	return rotated, skipped, nil
+}
@@ -0,0 +1,147 @@
+// Package currency converts offer amounts into USD using a local exchange-rate table
+package currency
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Base currency every amount is normalized to
+const USD = "USD"
+
+// Table is the on-disk format of the exchange-rate file
+// Rates hold how many USD one unit of the currency is worth, e.g. "CAD": 0.73
+type Table struct {
+	UpdatedAt time.Time          `json:"updated_at"`
+	Rates     map[string]float64 `json:"rates"`
+}
+
+var (
+	mu    sync.RWMutex
+	path  string
+	table = Table{Rates: map[string]float64{USD: 1}}
+)
+
+func Init() {
+	/*
+		Loads the exchange-rate table from EXCHANGE_RATES_FILE (default exchange_rates.json)
+		Falls back to USD only if the file can't be read so offers still work
+	*/
+	path = os.Getenv("EXCHANGE_RATES_FILE")
+	if path == "" {
+		path = "exchange_rates.json"
+	}
+	if err := Reload(); err != nil {
+		log.Println("Failed to load exchange rates, only USD offers will be accepted: ", err)
+		return
+	}
+	log.Printf("Loaded %d exchange rates from %s\n", len(Rates().Rates), path)
+}
+
+// Reload re-reads the exchange-rate file, keeping the current table if it is invalid
+func Reload() error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var next Table
+	if err := json.Unmarshal(data, &next); err != nil {
+		return err
+	}
+	return set(next)
+}
+
+// Save validates a new table, writes it to the exchange-rate file and starts using it
+func Save(next Table) error {
+	if err := validate(&next); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	return set(next)
+}
+
+// Helper function to normalize codes and make sure every rate is usable
+func validate(next *Table) error {
+	rates := map[string]float64{USD: 1}
+	for code, rate := range next.Rates {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if len(code) != 3 {
+			return fmt.Errorf("invalid currency code %q", code)
+		}
+		if rate <= 0 {
+			return fmt.Errorf("rate for %s must be greater than 0", code)
+		}
+		if code != USD {
+			rates[code] = rate
+		}
+	}
+	next.Rates = rates
+	if next.UpdatedAt.IsZero() {
+		next.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func set(next Table) error {
+	if err := validate(&next); err != nil {
+		return err
+	}
+	mu.Lock()
+	table = next
+	mu.Unlock()
+	return nil
+}
+
+// Rates returns a copy of the current table
+func Rates() Table {
+	mu.RLock()
+	defer mu.RUnlock()
+	rates := make(map[string]float64, len(table.Rates))
+	for code, rate := range table.Rates {
+		rates[code] = rate
+	}
+	return Table{UpdatedAt: table.UpdatedAt, Rates: rates}
+}
+
+// Normalize uppercases a currency code, defaulting to USD when empty
+func Normalize(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return USD
+	}
+	return code
+}
+
+// Supported reports whether there is a rate for the currency
+func Supported(code string) bool {
+	_, ok := Rate(code)
+	return ok
+}
+
+// Rate returns how many USD one unit of the currency is worth
+func Rate(code string) (float64, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	rate, ok := table.Rates[Normalize(code)]
+	return rate, ok
+}
+
+// ToUSD converts an amount to USD, returning false if the currency has no rate
+func ToUSD(amount float64, code string) (float64, bool) {
+	rate, ok := Rate(code)
+	if !ok {
+		return 0, false
+	}
+	return amount * rate, true
+}
@@ -0,0 +1,435 @@
+package db
+
+import (
+	"context"
+	"log"
+)
+
+// Schema changes for features added after the initial tables were created.
+// Every statement must be idempotent since they run on each startup.
+var migrations = []string{
+	// Post-event feedback surveys
+	`CREATE TABLE IF NOT EXISTS event_surveys (
+		id SERIAL PRIMARY KEY,
+		event_id INTEGER NOT NULL UNIQUE REFERENCES events(id) ON DELETE CASCADE,
+		title TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS event_survey_questions (
+		id SERIAL PRIMARY KEY,
+		survey_id INTEGER NOT NULL REFERENCES event_surveys(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL DEFAULT 0,
+		kind TEXT NOT NULL,
+		prompt TEXT NOT NULL,
+		options TEXT[] NOT NULL DEFAULT '{}',
+		required BOOLEAN NOT NULL DEFAULT FALSE
+	)`,
+	`CREATE TABLE IF NOT EXISTS event_survey_responses (
+		id SERIAL PRIMARY KEY,
+		survey_id INTEGER NOT NULL REFERENCES event_surveys(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		submitted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (survey_id, user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS event_survey_answers (
+		id SERIAL PRIMARY KEY,
+		response_id INTEGER NOT NULL REFERENCES event_survey_responses(id) ON DELETE CASCADE,
+		question_id INTEGER NOT NULL REFERENCES event_survey_questions(id) ON DELETE CASCADE,
+		rating INTEGER,
+		choice TEXT,
+		text_value TEXT
+	)`,
+
+	// Event draft/publish/cancel lifecycle
+	`ALTER TABLE events ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published'`,
+	`ALTER TABLE events ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ`,
+	`ALTER TABLE events ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ`,
+	`ALTER TABLE events ADD COLUMN IF NOT EXISTS cancel_reason TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE event_registrations ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'registered'`,
+
+	// Event hosts and speakers
+	`CREATE TABLE IF NOT EXISTS event_hosts (
+		event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		role TEXT NOT NULL DEFAULT 'host',
+		added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (event_id, user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS event_speakers (
+		id SERIAL PRIMARY KEY,
+		event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		bio TEXT NOT NULL DEFAULT '',
+		photo_url TEXT NOT NULL DEFAULT '',
+		links TEXT[] NOT NULL DEFAULT '{}',
+		position INTEGER NOT NULL DEFAULT 0
+	)`,
+
+	// Attendee check-in and registration time for organizer exports
+	`ALTER TABLE event_registrations ADD COLUMN IF NOT EXISTS registered_at TIMESTAMPTZ NOT NULL DEFAULT NOW()`,
+	`ALTER TABLE event_registrations ADD COLUMN IF NOT EXISTS checked_in_at TIMESTAMPTZ`,
+
+	// Email notifications, waitlists and per-user opt-outs
+	`ALTER TABLE events ADD COLUMN IF NOT EXISTS capacity INTEGER NOT NULL DEFAULT 0`,
+	`CREATE TABLE IF NOT EXISTS notification_preferences (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		confirmations BOOLEAN NOT NULL DEFAULT TRUE,
+		reminders BOOLEAN NOT NULL DEFAULT TRUE,
+		waitlist BOOLEAN NOT NULL DEFAULT TRUE,
+		cancellations BOOLEAN NOT NULL DEFAULT TRUE,
+		announcements BOOLEAN NOT NULL DEFAULT TRUE,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS notification_log (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+		kind TEXT NOT NULL,
+		sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (user_id, event_id, kind)
+	)`,
+
+	// Anonymous offers with an opt-in to show the submitter
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS show_name BOOLEAN NOT NULL DEFAULT FALSE`,
+
+	// Offer moderation queue and reports
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'approved'`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS flag_reasons TEXT[] NOT NULL DEFAULT '{}'`,
+	`CREATE TABLE IF NOT EXISTS offer_reports (
+		id SERIAL PRIMARY KEY,
+		offer_id INTEGER NOT NULL REFERENCES offers(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		reason TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		resolved_at TIMESTAMPTZ,
+		UNIQUE (offer_id, user_id)
+	)`,
+	// Existing accounts keep a NULL created_at so they never count as new
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ`,
+	`ALTER TABLE users ALTER COLUMN created_at SET DEFAULT NOW()`,
+
+	// Full compensation breakdown for offers
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS level TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS season TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS year INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS work_mode TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS return_offer BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS base_salary NUMERIC NOT NULL DEFAULT 0`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS signing_bonus NUMERIC NOT NULL DEFAULT 0`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS relocation NUMERIC NOT NULL DEFAULT 0`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS housing_stipend NUMERIC NOT NULL DEFAULT 0`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS stock_amount NUMERIC NOT NULL DEFAULT 0`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS stock_vesting TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS vesting_years INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS total_comp NUMERIC NOT NULL DEFAULT 0`,
+	`UPDATE offers SET total_comp = hourly_rate * 2080 WHERE total_comp = 0 AND hourly_rate > 0 AND offer_type = 'full-time'`,
+	// Internships have no annual total comp, earlier versions annualized their hourly rate
+	`UPDATE offers SET total_comp = 0 WHERE offer_type = 'internship' AND total_comp <> 0`,
+
+	// Offers in currencies other than USD
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD'`,
+
+	// Application timelines and interview rounds shared with offers
+	`CREATE TABLE IF NOT EXISTS offer_timelines (
+		offer_id INTEGER PRIMARY KEY REFERENCES offers(id) ON DELETE CASCADE,
+		applied_on DATE,
+		oa_on DATE,
+		phone_screen_on DATE,
+		onsite_on DATE,
+		offer_on DATE,
+		decision_on DATE,
+		decision TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS offer_interview_rounds (
+		id SERIAL PRIMARY KEY,
+		offer_id INTEGER NOT NULL REFERENCES offers(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL DEFAULT 0,
+		kind TEXT NOT NULL,
+		difficulty INTEGER NOT NULL DEFAULT 0,
+		topics TEXT[] NOT NULL DEFAULT '{}',
+		notes TEXT NOT NULL DEFAULT ''
+	)`,
+
+	// Company registry so spellings like "CapitalOne" and "capital one" count as one company
+	`CREATE TABLE IF NOT EXISTS companies (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		domain TEXT NOT NULL DEFAULT '',
+		logo_url TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS company_aliases (
+		alias TEXT PRIMARY KEY, -- lowercase letters and digits only
+		company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE
+	)`,
+	`ALTER TABLE offers ADD COLUMN IF NOT EXISTS company_id INTEGER REFERENCES companies(id) ON DELETE SET NULL`,
+	`ALTER TABLE work_history ADD COLUMN IF NOT EXISTS company_id INTEGER REFERENCES companies(id) ON DELETE SET NULL`,
+	// Well-known companies that used to be hardcoded for logos
+	`INSERT INTO companies (name, domain) VALUES
+		('Google', 'google.com'),
+		('Datadog', 'datadoghq.com'),
+		('Capital One', 'capitalone.com'),
+		('Amazon', 'amazon.com'),
+		('Microsoft', 'microsoft.com'),
+		('Meta', 'meta.com'),
+		('LinkedIn', 'linkedin.com'),
+		('Apple', 'apple.com'),
+		('Netflix', 'netflix.com'),
+		('Tesla', 'tesla.com'),
+		('Facebook', 'facebook.com'),
+		('Twitter', 'twitter.com'),
+		('Uber', 'uber.com'),
+		('Airbnb', 'airbnb.com'),
+		('Spotify', 'spotify.com'),
+		('Salesforce', 'salesforce.com'),
+		('Adobe', 'adobe.com'),
+		('Oracle', 'oracle.com'),
+		('IBM', 'ibm.com'),
+		('Intel', 'intel.com'),
+		('Cisco', 'cisco.com'),
+		('Dell', 'dell.com'),
+		('HP', 'hp.com'),
+		('Slack', 'slack.com'),
+		('Zoom', 'zoom.us'),
+		('Dropbox', 'dropbox.com'),
+		('GitHub', 'github.com'),
+		('Reddit', 'reddit.com'),
+		('Pinterest', 'pinterest.com'),
+		('Snapchat', 'snap.com'),
+		('TikTok', 'tiktok.com'),
+		('Stripe', 'stripe.com'),
+		('Square', 'squareup.com'),
+		('PayPal', 'paypal.com'),
+		('Venmo', 'venmo.com'),
+		('Goldman Sachs', 'goldmansachs.com'),
+		('Morgan Stanley', 'morganstanley.com'),
+		('JPMorgan', 'jpmorgan.com'),
+		('Bank of America', 'bankofamerica.com'),
+		('Wells Fargo', 'wellsfargo.com'),
+		('Deloitte', 'deloitte.com'),
+		('PwC', 'pwc.com'),
+		('EY', 'ey.com'),
+		('KPMG', 'kpmg.com'),
+		('Accenture', 'accenture.com'),
+		('McKinsey', 'mckinsey.com')
+	 ON CONFLICT (name) DO NOTHING`,
+	// Existing offers and work history are linked to companies from Go on startup, see handlers.BackfillNameRegistries
+
+	// School registry with CUNY/SUNY system grouping
+	`CREATE TABLE IF NOT EXISTS schools (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		domain TEXT NOT NULL DEFAULT '',
+		logo_url TEXT NOT NULL DEFAULT '',
+		campus TEXT NOT NULL DEFAULT '',
+		system TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS school_aliases (
+		alias TEXT PRIMARY KEY, -- lowercase letters and digits only
+		school_id INTEGER NOT NULL REFERENCES schools(id) ON DELETE CASCADE
+	)`,
+	`ALTER TABLE education_history ADD COLUMN IF NOT EXISTS school_id INTEGER REFERENCES schools(id) ON DELETE SET NULL`,
+	// Well-known schools that used to be hardcoded for logos
+	`INSERT INTO schools (name, domain, campus, system) VALUES
+		('Queens College', 'qc.cuny.edu', 'Queens, NY', 'CUNY'),
+		('Baruch College', 'baruch.cuny.edu', 'Manhattan, NY', 'CUNY'),
+		('Hunter College', 'hunter.cuny.edu', 'Manhattan, NY', 'CUNY'),
+		('City College of New York', 'ccny.cuny.edu', 'Manhattan, NY', 'CUNY'),
+		('Brooklyn College', 'brooklyn.cuny.edu', 'Brooklyn, NY', 'CUNY'),
+		('Lehman College', 'lehman.cuny.edu', 'Bronx, NY', 'CUNY'),
+		('College of Staten Island', 'csi.cuny.edu', 'Staten Island, NY', 'CUNY'),
+		('John Jay College of Criminal Justice', 'jjay.cuny.edu', 'Manhattan, NY', 'CUNY'),
+		('York College', 'york.cuny.edu', 'Queens, NY', 'CUNY'),
+		('Medgar Evers College', 'mec.cuny.edu', 'Brooklyn, NY', 'CUNY'),
+		('New York City College of Technology', 'citytech.cuny.edu', 'Brooklyn, NY', 'CUNY'),
+		('Stony Brook University', 'stonybrook.edu', 'Stony Brook, NY', 'SUNY'),
+		('University at Buffalo', 'buffalo.edu', 'Buffalo, NY', 'SUNY'),
+		('Binghamton University', 'binghamton.edu', 'Binghamton, NY', 'SUNY'),
+		('University at Albany', 'albany.edu', 'Albany, NY', 'SUNY'),
+		('Columbia University', 'columbia.edu', 'New York, NY', ''),
+		('Harvard University', 'harvard.edu', 'Cambridge, MA', ''),
+		('Massachusetts Institute of Technology', 'mit.edu', 'Cambridge, MA', ''),
+		('Stanford University', 'stanford.edu', 'Stanford, CA', ''),
+		('Yale University', 'yale.edu', 'New Haven, CT', ''),
+		('Princeton University', 'princeton.edu', 'Princeton, NJ', ''),
+		('University of Pennsylvania', 'upenn.edu', 'Philadelphia, PA', ''),
+		('Cornell University', 'cornell.edu', 'Ithaca, NY', ''),
+		('Brown University', 'brown.edu', 'Providence, RI', ''),
+		('Dartmouth College', 'dartmouth.edu', 'Hanover, NH', ''),
+		('Duke University', 'duke.edu', 'Durham, NC', ''),
+		('Northwestern University', 'northwestern.edu', 'Evanston, IL', ''),
+		('University of Chicago', 'uchicago.edu', 'Chicago, IL', ''),
+		('California Institute of Technology', 'caltech.edu', 'Pasadena, CA', ''),
+		('University of California, Berkeley', 'berkeley.edu', 'Berkeley, CA', ''),
+		('University of California, Los Angeles', 'ucla.edu', 'Los Angeles, CA', ''),
+		('New York University', 'nyu.edu', 'New York, NY', ''),
+		('University of Michigan', 'umich.edu', 'Ann Arbor, MI', ''),
+		('Georgia Institute of Technology', 'gatech.edu', 'Atlanta, GA', ''),
+		('Carnegie Mellon University', 'cmu.edu', 'Pittsburgh, PA', ''),
+		('University of Texas at Austin', 'utexas.edu', 'Austin, TX', ''),
+		('University of Virginia', 'virginia.edu', 'Charlottesville, VA', ''),
+		('Boston University', 'bu.edu', 'Boston, MA', ''),
+		('Georgetown University', 'georgetown.edu', 'Washington, DC', ''),
+		('Emory University', 'emory.edu', 'Atlanta, GA', ''),
+		('Vanderbilt University', 'vanderbilt.edu', 'Nashville, TN', ''),
+		('Rice University', 'rice.edu', 'Houston, TX', ''),
+		('University of Southern California', 'usc.edu', 'Los Angeles, CA', '')
+	 ON CONFLICT (name) DO NOTHING`,
+	`INSERT INTO school_aliases (alias, school_id)
+	 SELECT regexp_replace(lower(v.alias), '[^a-z0-9]', '', 'g'), s.id
+	 FROM (VALUES
+		('QC', 'Queens College'),
+		('CUNY Queens College', 'Queens College'),
+		('CUNY Baruch', 'Baruch College'),
+		('CUNY Hunter', 'Hunter College'),
+		('CCNY', 'City College of New York'),
+		('City College', 'City College of New York'),
+		('CUNY Brooklyn College', 'Brooklyn College'),
+		('CSI', 'College of Staten Island'),
+		('John Jay College', 'John Jay College of Criminal Justice'),
+		('City Tech', 'New York City College of Technology'),
+		('SUNY Stony Brook', 'Stony Brook University'),
+		('SUNY Buffalo', 'University at Buffalo'),
+		('UB', 'University at Buffalo'),
+		('SUNY Binghamton', 'Binghamton University'),
+		('SUNY Albany', 'University at Albany'),
+		('MIT', 'Massachusetts Institute of Technology'),
+		('UPenn', 'University of Pennsylvania'),
+		('UChicago', 'University of Chicago'),
+		('Caltech', 'California Institute of Technology'),
+		('UC Berkeley', 'University of California, Berkeley'),
+		('UCLA', 'University of California, Los Angeles'),
+		('NYU', 'New York University'),
+		('Georgia Tech', 'Georgia Institute of Technology'),
+		('CMU', 'Carnegie Mellon University'),
+		('UT Austin', 'University of Texas at Austin'),
+		('UVA', 'University of Virginia'),
+		('USC', 'University of Southern California')
+	 ) AS v(alias, name)
+	 JOIN schools s ON s.name = v.name
+	 ON CONFLICT (alias) DO NOTHING`,
+	// Existing education history is linked to schools from Go on startup, see handlers.BackfillNameRegistries
+
+	// Logos fetched from logo.dev, served by /api/logos/:domain
+	`CREATE TABLE IF NOT EXISTS logo_cache (
+		domain TEXT PRIMARY KEY,
+		content_type TEXT NOT NULL DEFAULT '',
+		data BYTEA,
+		missing BOOLEAN NOT NULL DEFAULT FALSE,
+		fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		expires_at TIMESTAMPTZ NOT NULL
+	)`,
+
+	// Public GitHub activity synced in the background
+	`CREATE TABLE IF NOT EXISTS github_stats (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		contributions_last_year INTEGER NOT NULL DEFAULT 0,
+		followers INTEGER NOT NULL DEFAULT 0,
+		following INTEGER NOT NULL DEFAULT 0,
+		public_repos INTEGER NOT NULL DEFAULT 0,
+		languages JSONB NOT NULL DEFAULT '[]',
+		recent_pull_requests JSONB NOT NULL DEFAULT '[]',
+		synced_at TIMESTAMPTZ,
+		sync_error TEXT NOT NULL DEFAULT ''
+	)`,
+
+	// Cached details of pinned GitHub repos, refreshed with conditional requests
+	`CREATE TABLE IF NOT EXISTS github_repo_cache (
+		full_name TEXT PRIMARY KEY, -- owner/repo, lowercase
+		data JSONB NOT NULL,
+		etag TEXT NOT NULL DEFAULT '',
+		fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+
+	// Discord roles the bot assigns from platform data, value is a school ID, school system or event ID
+	`CREATE TABLE IF NOT EXISTS discord_role_mappings (
+		id SERIAL PRIMARY KEY,
+		attribute TEXT NOT NULL,
+		value TEXT NOT NULL DEFAULT '',
+		role_id TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (attribute, value)
+	)`,
+	`ALTER TABLE discord_integrations ADD COLUMN IF NOT EXISTS roles_synced_at TIMESTAMPTZ`,
+	`ALTER TABLE discord_integrations ADD COLUMN IF NOT EXISTS role_sync_error TEXT NOT NULL DEFAULT ''`,
+
+	// Discord announcement message and scheduled event mirroring each published event
+	`ALTER TABLE events ADD COLUMN IF NOT EXISTS discord_message_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE events ADD COLUMN IF NOT EXISTS discord_scheduled_event_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE event_registrations ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'platform'`,
+
+	// When guild membership was last checked, the re-verification job works oldest first
+	`ALTER TABLE discord_integrations ADD COLUMN IF NOT EXISTS last_verified_at TIMESTAMPTZ`,
+
+	// LeetCode accounts linked by username, stats are synced from LeetCode's public API
+	`CREATE TABLE IF NOT EXISTS leetcode_integrations (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		username TEXT NOT NULL,
+		avatar_url TEXT NOT NULL DEFAULT '',
+		ranking INTEGER NOT NULL DEFAULT 0,
+		easy_solved INTEGER NOT NULL DEFAULT 0,
+		medium_solved INTEGER NOT NULL DEFAULT 0,
+		hard_solved INTEGER NOT NULL DEFAULT 0,
+		total_solved INTEGER NOT NULL DEFAULT 0,
+		contest_rating DOUBLE PRECISION NOT NULL DEFAULT 0,
+		contests_attended INTEGER NOT NULL DEFAULT 0,
+		recent_submissions JSONB NOT NULL DEFAULT '[]',
+		linked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		synced_at TIMESTAMPTZ,
+		sync_error TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS leetcode_integrations_username_idx ON leetcode_integrations (LOWER(username))`,
+
+	// One row per member per day, the latest sync of the day wins
+	`CREATE TABLE IF NOT EXISTS leetcode_snapshots (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		snapshot_date DATE NOT NULL DEFAULT CURRENT_DATE,
+		easy_solved INTEGER NOT NULL DEFAULT 0,
+		medium_solved INTEGER NOT NULL DEFAULT 0,
+		hard_solved INTEGER NOT NULL DEFAULT 0,
+		total_solved INTEGER NOT NULL DEFAULT 0,
+		contest_rating DOUBLE PRECISION NOT NULL DEFAULT 0,
+		PRIMARY KEY (user_id, snapshot_date)
+	)`,
+
+	// Logo images moved to Cloudinary, the cache only records where they are
+	`ALTER TABLE logo_cache ADD COLUMN IF NOT EXISTS image_url TEXT NOT NULL DEFAULT ''`,
+	`DELETE FROM logo_cache WHERE image_url = '' AND NOT missing`,
+	`ALTER TABLE logo_cache DROP COLUMN IF EXISTS data`,
+	`ALTER TABLE logo_cache DROP COLUMN IF EXISTS content_type`,
+	// Logo cache URLs are built when rows are read, only explicitly set logos stay stored
+	`UPDATE offers SET company_logo_url = '' WHERE company_logo_url LIKE 'https://img.logo.dev/%' OR company_logo_url LIKE '%/api/logos/%'`,
+	`UPDATE work_history SET company_logo_url = '' WHERE company_logo_url LIKE 'https://img.logo.dev/%' OR company_logo_url LIKE '%/api/logos/%'`,
+	`UPDATE education_history SET school_logo_url = '' WHERE school_logo_url LIKE 'https://img.logo.dev/%' OR school_logo_url LIKE '%/api/logos/%'`,
+
+	// Repo cache entries belong to the member whose token fetched them, tokens can see different repos
+	`ALTER TABLE github_repo_cache ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE`,
+	`DELETE FROM github_repo_cache WHERE user_id IS NULL`,
+	`ALTER TABLE github_repo_cache DROP CONSTRAINT IF EXISTS github_repo_cache_pkey`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS github_repo_cache_user_repo_idx ON github_repo_cache (user_id, full_name)`,
+	// Repos GitHub answered 404 for, kept so profile views don't fetch them again until the next refresh
+	`ALTER TABLE github_repo_cache ADD COLUMN IF NOT EXISTS not_found BOOLEAN NOT NULL DEFAULT FALSE`,
+
+	// Removed registrations and where the member withdrew, Discord interest never overrides a platform withdrawal
+	`CREATE TABLE IF NOT EXISTS event_registration_withdrawals (
+		event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		source TEXT NOT NULL,
+		withdrawn_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (event_id, user_id)
+	)`,
+}
+
+func Migrate() {
+	/*
+		Applies the migrations above in order
+		Exits if any statement fails so we never run against a half-migrated schema
+	*/
+	for i, stmt := range migrations {
+		if _, err := Pool.Exec(context.Background(), stmt); err != nil {
+			log.Fatalf("Failed to apply migration %d: %v\n", i, err)
+		}
+	}
+	log.Println("Database migrations applied")
+}
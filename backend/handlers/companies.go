@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Legal suffixes ignored when matching, so "Stripe, Inc." resolves to "Stripe"
+var companySuffixes = map[string]bool{
+	"inc": true, "incorporated": true, "llc": true, "ltd": true, "limited": true, "corp": true,
+	"corporation": true, "co": true, "company": true, "plc": true, "gmbh": true, "lp": true, "llp": true,
+}
+
+// Helper function to build the key company names are matched on
+func companyKey(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for len(words) > 1 && companySuffixes[words[len(words)-1]] {
+		words = words[:len(words)-1]
+	}
+	return nameKey(strings.Join(words, ""))
+}
+
+var companyRegistry = nameRegistry{aliasTable: "company_aliases", idColumn: "company_id", key: companyKey}
+
+// Helper function to load a company by ID
+func loadCompany(id int) (models.Company, error) {
+	company := models.Company{ID: id}
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT name, domain, logo_url, created_at FROM companies WHERE id = $1`, id).
+		Scan(&company.Name, &company.Domain, &company.LogoURL, &company.CreatedAt)
+	return company, err
+}
+
+// Helper function to map a company name typed by a member to a registered company
+// Tries exact aliases and registers a new company if nothing matches, close spellings show up as duplicates for admins
+func resolveCompany(name string) (models.Company, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if companyKey(name) == "" {
+		return models.Company{}, errors.New("company name is empty")
+	}
+
+	id, ok, err := companyRegistry.match(name)
+	if err != nil {
+		return models.Company{}, err
+	}
+	if ok {
+		return loadCompany(id)
+	}
+
+	err = db.Pool.QueryRow(context.Background(),
+		`INSERT INTO companies (name) VALUES ($1)
+		 ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		 RETURNING id`, name).Scan(&id)
+	if err != nil {
+		return models.Company{}, err
+	}
+	if err := companyRegistry.addAliases(id, name); err != nil {
+		return models.Company{}, err
+	}
+	return loadCompany(id)
+}
+
+// Helper function to load companies by name with their aliases and usage counts, every company when limit is 0
+func loadCompanies(limit, offset int) ([]models.Company, error) {
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT c.id, c.name, c.domain, c.logo_url, c.created_at,
+			COALESCE((SELECT array_agg(a.alias ORDER BY a.alias) FROM company_aliases a WHERE a.company_id = c.id), '{}'),
+			(SELECT COUNT(*) FROM offers o WHERE o.company_id = c.id),
+			(SELECT COUNT(*) FROM work_history w WHERE w.company_id = c.id)
+		 FROM companies c
+		 ORDER BY c.name
+		 LIMIT NULLIF($1, 0) OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	companies := []models.Company{}
+	for rows.Next() {
+		var company models.Company
+		if err := rows.Scan(&company.ID, &company.Name, &company.Domain, &company.LogoURL, &company.CreatedAt,
+			&company.Aliases, &company.OfferCount, &company.WorkCount); err != nil {
+			return nil, err
+		}
+		companies = append(companies, company)
+	}
+	return companies, rows.Err()
+}
+
+// GET /api/companies?q=capital
+// GET /api/companies?limit=50&offset=0
+func SearchCompanies(c *fiber.Ctx) error {
+	/*
+		Autocompletes company names
+		Matches the start of any alias first, then close spellings
+		Without a query, pages through companies by name (limit defaults to 50, at most 200)
+	*/
+	query := companyKey(c.Query("q"))
+	if query == "" {
+		limit := c.QueryInt("limit", defaultRegistryPageSize)
+		if limit < 1 || limit > maxRegistryPageSize {
+			limit = defaultRegistryPageSize
+		}
+		companies, err := loadCompanies(limit, max(c.QueryInt("offset", 0), 0))
+		if err != nil {
+			log.Println("DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+		}
+		return c.JSON(companies)
+	}
+
+	companies, err := loadCompanies(0, 0)
+	if err != nil {
+		log.Println("DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+
+	type scored struct {
+		company models.Company
+		score   float64
+	}
+	matches := []scored{}
+	for _, company := range companies {
+		if score := searchScore(query, company.Aliases); score >= fuzzyMatchThreshold {
+			matches = append(matches, scored{company, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	results := []models.Company{}
+	for i := 0; i < len(matches) && i < registrySearchLimit; i++ {
+		results = append(results, matches[i].company)
+	}
+	return c.JSON(results)
+}
+
+// Helper function to refresh the name and logo stored on a company's offers and work history
+// Only an explicitly set logo is stored, logo cache URLs are built from the domain when rows are read
+func syncCompanyReferences(tx pgx.Tx, company models.Company) error {
+	logo := company.LogoURL
+	if _, err := tx.Exec(context.Background(),
+		`UPDATE offers SET company = $1, company_logo_url = $2 WHERE company_id = $3`,
+		company.Name, logo, company.ID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(context.Background(),
+		`UPDATE work_history SET company = $1, company_logo_url = $2 WHERE company_id = $3`,
+		company.Name, logo, company.ID)
+	return err
+}
+
+// POST /api/admin/companies (ADMIN ONLY)
+// Body: { "name": "Capital One", "domain": "capitalone.com", "aliases": ["COF"] }
+func CreateCompany(c *fiber.Ctx) error {
+	/*
+		Registers a company ahead of members entering it
+	*/
+	var body models.Company
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+	body.Name = strings.Join(strings.Fields(body.Name), " ")
+	if companyKey(body.Name) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Company name is required"})
+	}
+
+	// Refuse spellings that already belong to another company, merging is the way to combine them
+	for _, name := range append([]string{body.Name}, body.Aliases...) {
+		if _, ok, err := companyRegistry.ownerOf(companyKey(name)); err != nil {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+		} else if ok {
+			return c.Status(409).JSON(fiber.Map{"error": "A company named " + name + " already exists"})
+		}
+	}
+
+	var id int
+	err := db.Pool.QueryRow(context.Background(),
+		`INSERT INTO companies (name, domain, logo_url) VALUES ($1, $2, $3) RETURNING id`,
+		body.Name, strings.ToLower(strings.TrimSpace(body.Domain)), strings.TrimSpace(body.LogoURL)).Scan(&id)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+	}
+	for _, name := range append([]string{body.Name}, body.Aliases...) {
+		if err := companyRegistry.addAliases(id, name); err != nil {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+		}
+	}
+
+	return c.JSON(fiber.Map{"message": "Company created successfully", "id": id})
+}
+
+// PUT /api/admin/companies/:id (ADMIN ONLY)
+// Body: { "name": "Capital One", "domain": "capitalone.com", "logo_url": "" }
+func UpdateCompany(c *fiber.Ctx) error {
+	/*
+		Renames a company or changes its logo
+		Offers and work history pointing at it are updated to the new name and logo
+		The old name keeps resolving to the company
+	*/
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid company ID"})
+	}
+
+	var body models.Company
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+	body.ID = id
+	body.Name = strings.Join(strings.Fields(body.Name), " ")
+	body.Domain = strings.ToLower(strings.TrimSpace(body.Domain))
+	body.LogoURL = strings.TrimSpace(body.LogoURL)
+	if companyKey(body.Name) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Company name is required"})
+	}
+	if owner, ok, err := companyRegistry.ownerOf(companyKey(body.Name)); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	} else if ok && owner != id {
+		return c.Status(409).JSON(fiber.Map{"error": "Another company already uses that name, merge them instead"})
+	}
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(context.Background())
+
+	result, err := tx.Exec(context.Background(),
+		`UPDATE companies SET name = $1, domain = $2, logo_url = $3 WHERE id = $4`,
+		body.Name, body.Domain, body.LogoURL, id)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+	if result.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Company not found"})
+	}
+	if err := syncCompanyReferences(tx, body); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+
+	if err := companyRegistry.addAliases(id, body.Name); err != nil {
+		log.Println("Internal DB Error: ", err)
+	}
+	return c.JSON(fiber.Map{"message": "Company updated successfully"})
+}
+
+// POST /api/admin/companies/:id/aliases (ADMIN ONLY)
+// Body: { "alias": "COF" }
+func AddCompanyAlias(c *fiber.Ctx) error {
+	/*
+		Adds an alternate spelling that resolves to the company
+	*/
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid company ID"})
+	}
+
+	var body struct {
+		Alias string `json:"alias"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+	key := companyKey(body.Alias)
+	if key == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Alias is required"})
+	}
+
+	if owner, ok, err := companyRegistry.ownerOf(key); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	} else if ok && owner != id {
+		return c.Status(409).JSON(fiber.Map{"error": "That alias belongs to another company, merge them instead"})
+	}
+
+	_, err = db.Pool.Exec(context.Background(),
+		`INSERT INTO company_aliases (alias, company_id) VALUES ($1, $2) ON CONFLICT (alias) DO NOTHING`, key, id)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(400).JSON(fiber.Map{"error": "Company not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Alias added successfully", "alias": key})
+}
+
+// DELETE /api/admin/companies/:id/aliases/:alias (ADMIN ONLY)
+func DeleteCompanyAlias(c *fiber.Ctx) error {
+	/*
+		Removes an alternate spelling from a company
+		The company's current name always stays an alias
+	*/
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid company ID"})
+	}
+	company, err := loadCompany(id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Company not found"})
+	}
+
+	alias := c.Params("alias")
+	if alias == companyKey(company.Name) || alias == nameKey(company.Name) {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot remove the company's own name"})
+	}
+
+	result, err := db.Pool.Exec(context.Background(),
+		`DELETE FROM company_aliases WHERE alias = $1 AND company_id = $2`, alias, id)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database delete failed"})
+	}
+	if result.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Alias not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Alias removed successfully"})
+}
+
+// GET /api/admin/companies/duplicates (ADMIN ONLY)
+func GetCompanyDuplicates(c *fiber.Ctx) error {
+	/*
+		Suggests pairs of companies that are probably the same, most similar first
+		The company with more offers and work history is suggested as the one to keep
+		Members' close spellings ("Googly") land here rather than being merged automatically
+	*/
+	companies, err := loadCompanies(0, 0)
+	if err != nil {
+		log.Println("DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+
+	matches := []models.CompanyMatch{}
+	for i := range companies {
+		for j := i + 1; j < len(companies); j++ {
+			a, b := companies[i], companies[j]
+			best := aliasSimilarity(a.Aliases, b.Aliases)
+			if best < fuzzyMatchThreshold {
+				continue
+			}
+			if b.OfferCount+b.WorkCount > a.OfferCount+a.WorkCount {
+				a, b = b, a
+			}
+			matches = append(matches, models.CompanyMatch{Company: a, Duplicate: b, Similarity: best})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Similarity > matches[j].Similarity })
+	return c.JSON(matches)
+}
+
+// POST /api/admin/companies/:id/merge (ADMIN ONLY)
+// Body: { "source_ids": [12, 40] }
+func MergeCompanies(c *fiber.Ctx) error {
+	/*
+		Merges duplicate companies into the company in the URL
+		Offers, work history and aliases of the duplicates move over and the duplicates are deleted
+	*/
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid company ID"})
+	}
+
+	var body struct {
+		SourceIDs []int `json:"source_ids"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+	sources := []int{}
+	for _, source := range body.SourceIDs {
+		if source != id {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "No companies to merge"})
+	}
+
+	target, err := loadCompany(id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Company not found"})
+	}
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(context.Background())
+
+	statements := []string{
+		`UPDATE offers SET company_id = $1 WHERE company_id = ANY($2)`,
+		`UPDATE work_history SET company_id = $1 WHERE company_id = ANY($2)`,
+		`UPDATE company_aliases SET company_id = $1 WHERE company_id = ANY($2)`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(context.Background(), stmt, id, sources); err != nil {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+		}
+	}
+
+	// Keep a logo if only a duplicate had one
+	if target.Domain == "" && target.LogoURL == "" {
+		err = tx.QueryRow(context.Background(),
+			`SELECT domain, logo_url FROM companies
+			 WHERE id = ANY($1) AND (domain <> '' OR logo_url <> '')
+			 ORDER BY id LIMIT 1`, sources).Scan(&target.Domain, &target.LogoURL)
+		if err == nil {
+			_, err = tx.Exec(context.Background(),
+				`UPDATE companies SET domain = $1, logo_url = $2 WHERE id = $3`, target.Domain, target.LogoURL, id)
+		}
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+		}
+	}
+
+	result, err := tx.Exec(context.Background(), `DELETE FROM companies WHERE id = ANY($1)`, sources)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database delete failed"})
+	}
+
+	if err := syncCompanyReferences(tx, target); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Companies merged successfully", "merged": result.RowsAffected()})
+}
@@ -2,20 +2,20 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"os"
+	"time"
 
 	"github.com/KerlynD/CFA_Member_Profile/backend/db"
 	"github.com/KerlynD/CFA_Member_Profile/backend/models"
 	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 	"github.com/ravener/discord-oauth2"
 	"golang.org/x/oauth2"
 )
 
-var discordOAuthConfig *oauth2.Config
 var discordBotToken string
 var discordGuildID string
 
@@ -25,7 +25,7 @@ func InitDiscordOAuth() {
 		Creates a new OAuth2 configuration for Discord authentication
 		Sets the RedirectURL, ClientID, ClientSecret, and Endpoint for Discord authentication
 	*/
-	discordOAuthConfig = &oauth2.Config{
+	discordProvider.config = &oauth2.Config{
 		RedirectURL:  os.Getenv("DISCORD_REDIRECT_URL"),
 		ClientID:     os.Getenv("DISCORD_CLIENT_ID"),
 		ClientSecret: os.Getenv("DISCORD_CLIENT_SECRET"),
@@ -35,28 +35,7 @@ func InitDiscordOAuth() {
 
 	discordBotToken = os.Getenv("DISCORD_BOT_TOKEN")
 	discordGuildID = os.Getenv("DISCORD_GUILD_ID")
-}
-
-func DiscordLogin(c *fiber.Ctx) error {
-	// Verify user is authenticated first
-	jwtToken := utils.GetTokenFromRequest(c)
-	if jwtToken == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	claims, err := utils.VerifyJWT(jwtToken)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	// Store user ID in state parameter to pass through OAuth flow
-	state := fmt.Sprintf("%d", claims.UserID)
-
-	// Generate Discord OAuth URL with user ID in state
-	url := discordOAuthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
-
-	// Return the redirect URL as JSON for frontend to handle
-	return c.JSON(fiber.Map{"redirect_url": url})
+	initDiscordEvents()
 }
 
 // GET /api/integrations/discord
@@ -73,10 +52,11 @@ func GetDiscordIntegration(c *fiber.Ctx) error {
 
 	var integration models.DiscordIntegration
 	err = db.Pool.QueryRow(context.Background(),
-		`SELECT id, discord_id, username, discriminator, avatar_url, verified, joined_at
+		`SELECT id, discord_id, username, discriminator, avatar_url, verified, joined_at, last_verified_at
 		 FROM discord_integrations WHERE user_id=$1`, claims.UserID).
 		Scan(&integration.ID, &integration.DiscordID, &integration.Username,
-			&integration.Discriminator, &integration.AvatarURL, &integration.Verified, &integration.JoinedAt)
+			&integration.Discriminator, &integration.AvatarURL, &integration.Verified, &integration.JoinedAt,
+			&integration.LastVerifiedAt)
 
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Discord not linked"})
@@ -96,99 +76,15 @@ func verifyDiscordMembership(discordID string) bool {
 		return false
 	}
 
-	// Discord API endpoint to get guild member
-	url := fmt.Sprintf("https://discord.com/api/v10/guilds/%s/members/%s", discordGuildID, discordID)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-	request, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		fmt.Println("Error creating request:", err)
-		return false
-	}
-
-	// Use bot token for authentication
-	request.Header.Set("Authorization", fmt.Sprintf("Bot %s", discordBotToken))
-
-	response, err := http.DefaultClient.Do(request)
+	inGuild, _, err := checkDiscordMembership(ctx, discordID)
 	if err != nil {
 		fmt.Println("Error checking guild membership:", err)
 		return false
 	}
-	defer response.Body.Close()
-
-	// 200 = user is in the server, 404 = user is not in the server
-	return response.StatusCode == 200
-}
-
-func DiscordCallback(c *fiber.Ctx) error {
-	/*
-		Handles the callback from Discord
-		Exchanges the authorization code for a token
-		Gets the user info from Discord
-		Creates/updates user in database
-		Redirects to the frontend
-	*/
-	code := c.Query("code")
-	state := c.Query("state") // This contains the user ID
-
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:3000"
-	}
-
-	if code == "" {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=discord_auth_failed")
-	}
-
-	if state == "" {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=discord_auth_failed")
-	}
-
-	oauthToken, err := discordOAuthConfig.Exchange(context.Background(), code)
-	if err != nil {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=discord_auth_failed")
-	}
-
-	// GET user info from Discord
-	request, _ := http.NewRequest("GET", "https://discord.com/api/v10/users/@me", nil)
-	request.Header.Set("Authorization", "Bearer "+oauthToken.AccessToken)
-
-	response, err := http.DefaultClient.Do(request)
-	if err != nil {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=discord_auth_failed")
-	}
-	defer response.Body.Close()
-
-	var userData map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&userData)
-
-	// Extract Discord user data
-	discordID := userData["id"].(string)
-	username := userData["username"].(string)
-	discriminator := userData["discriminator"].(string)
-	avatarHash := userData["avatar"].(string)
-	avatarURL := fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.png", discordID, avatarHash)
-
-	// Get user ID from state parameter (passed through OAuth flow)
-	userID := state
-
-	// Check if user is in the guild (verify membership)
-	isVerified := verifyDiscordMembership(discordID)
-
-	// Save to DB with verification status
-	_, err = db.Pool.Exec(context.Background(),
-		`INSERT INTO discord_integrations (user_id, discord_id, username, discriminator, avatar_url, verified)
-		 VALUES ($1, $2, $3, $4, $5, $6)
-		 ON CONFLICT (discord_id) 
-		 DO UPDATE SET username=$3, discriminator=$4, avatar_url=$5, verified=$6`,
-		userID, discordID, username, discriminator, avatarURL, isVerified)
-
-	if err != nil {
-		fmt.Printf("Error saving Discord integration: %v\n", err)
-		return c.Redirect(frontendURL + "/dashboard/profile?error=discord_save_failed")
-	}
-
-	// Redirect to profile with success
-	return c.Redirect(frontendURL + "/dashboard/profile?tab=integrations")
+	return inGuild
 }
 
 // POST /api/integrations/discord/verify
@@ -217,13 +113,17 @@ func VerifyDiscordMembership(c *fiber.Ctx) error {
 
 	// Update verified status in DB
 	_, err = db.Pool.Exec(context.Background(),
-		`UPDATE discord_integrations SET verified=$1 WHERE user_id=$2`,
+		`UPDATE discord_integrations SET verified=$1, last_verified_at=NOW() WHERE user_id=$2`,
 		isVerified, claims.UserID)
 
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update verification status"})
 	}
 
+	if isVerified {
+		queueDiscordRoleSync(claims.UserID)
+	}
+
 	return c.JSON(fiber.Map{
 		"verified": isVerified,
 		"message": func() string {
@@ -234,3 +134,104 @@ func VerifyDiscordMembership(c *fiber.Ctx) error {
 		}(),
 	})
 }
+
+// Discord accounts are connected through the generic integration routes
+type discordOAuthProvider struct {
+	oauthCodeFlow
+	apiURL string
+}
+
+var discordProvider = &discordOAuthProvider{apiURL: "https://discord.com/api/v10"}
+
+func init() {
+	registerIntegration(discordProvider)
+}
+
+func (p *discordOAuthProvider) Key() string  { return "discord" }
+func (p *discordOAuthProvider) Name() string { return "Discord" }
+
+func (p *discordOAuthProvider) FetchIdentity(ctx context.Context, token *oauth2.Token) (ProviderIdentity, error) {
+	var user struct {
+		ID            string `json:"id"`
+		Username      string `json:"username"`
+		Discriminator string `json:"discriminator"`
+		Avatar        string `json:"avatar"`
+	}
+	if err := fetchProviderJSON(ctx, p.apiURL+"/users/@me", token, &user); err != nil {
+		return ProviderIdentity{}, err
+	}
+
+	identity := ProviderIdentity{
+		ID:       user.ID,
+		Username: user.Username,
+		Extra:    map[string]string{"discriminator": user.Discriminator},
+	}
+	if user.Avatar != "" {
+		identity.AvatarURL = fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.png", user.ID, user.Avatar)
+	}
+	return identity, nil
+}
+
+// Discord tokens aren't stored, membership is checked with the bot token instead
+func (p *discordOAuthProvider) Persist(ctx context.Context, userID int, identity ProviderIdentity, token *oauth2.Token) error {
+	// Check if user is in the guild (verify membership)
+	isVerified := verifyDiscordMembership(identity.ID)
+
+	_, err := db.Pool.Exec(ctx,
+		`INSERT INTO discord_integrations (user_id, discord_id, username, discriminator, avatar_url, verified, last_verified_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		 ON CONFLICT (discord_id) 
+		 DO UPDATE SET username=$3, discriminator=$4, avatar_url=$5, verified=$6, last_verified_at=NOW()`,
+		userID, identity.ID, identity.Username, identity.Extra["discriminator"], identity.AvatarURL, isVerified)
+	if err != nil {
+		return err
+	}
+
+	if isVerified {
+		queueDiscordRoleSync(userID)
+	}
+	return nil
+}
+
+// No Discord token is stored, so there is no grant to revoke
+// Roles the bot assigned are taken off in the background
+func (p *discordOAuthProvider) Disconnect(ctx context.Context, userID int) error {
+	var discordID string
+	err := db.Pool.QueryRow(ctx,
+		`DELETE FROM discord_integrations WHERE user_id = $1 RETURNING discord_id`, userID).Scan(&discordID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errIntegrationNotLinked
+	}
+	if err != nil {
+		return err
+	}
+
+	go clearDiscordRoles(discordID)
+	return nil
+}
+
+// Reports the member's Discord link and server membership
+func (p *discordOAuthProvider) Status(ctx context.Context, userID int) (models.IntegrationStatus, error) {
+	status := models.IntegrationStatus{TokenHealth: models.TokenNotStored}
+
+	var username, avatar string
+	var verified bool
+	var joinedAt *time.Time
+	err := db.Pool.QueryRow(ctx,
+		`SELECT username, avatar_url, verified, joined_at FROM discord_integrations WHERE user_id = $1`,
+		userID,
+	).Scan(&username, &avatar, &verified, &joinedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+
+	status.Linked = true
+	status.Handle = username
+	status.AvatarURL = avatar
+	status.LinkedAt = joinedAt
+	status.Verified = &verified
+	return status, nil
+}
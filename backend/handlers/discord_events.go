@@ -0,0 +1,527 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/notifications"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// How often "interested" members on Discord scheduled events are turned into registrations
+	discordRSVPSyncInterval = 5 * time.Minute
+
+	discordEmbedColor          = 0x5865F2
+	discordEmbedCancelledColor = 0xED4245
+
+	// Discord scheduled event entity type for events outside a voice channel
+	discordScheduledEventExternal = 3
+	discordScheduledEventCanceled = 4
+	discordGuildOnly              = 2
+)
+
+var (
+	discordEventsChannelID  string
+	discordEventsWebhookURL string
+	discordRSVPSyncEnabled  bool
+)
+
+// Syncs run one at a time so two quick edits can't both post a new announcement
+var discordEventSyncMu sync.Mutex
+
+// Helper function to load the announcement settings, called from InitDiscordOAuth
+// DISCORD_EVENTS_CHANNEL_ID posts as the bot, otherwise DISCORD_EVENTS_WEBHOOK_URL is used
+// DISCORD_RSVP_SYNC=true registers linked members who mark a Discord scheduled event as interested
+func initDiscordEvents() {
+	discordEventsChannelID = os.Getenv("DISCORD_EVENTS_CHANNEL_ID")
+	discordEventsWebhookURL = strings.TrimRight(os.Getenv("DISCORD_EVENTS_WEBHOOK_URL"), "/")
+	discordRSVPSyncEnabled = os.Getenv("DISCORD_RSVP_SYNC") == "true"
+}
+
+// Helper function to mirror an event to Discord after it was created, edited, published or cancelled
+// Runs in the background so admins don't wait on Discord
+// Takes the event ID as given, handlers have it as a route parameter string
+func queueDiscordEventSync(eventID any) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := syncDiscordEvent(ctx, eventID); err != nil {
+			log.Printf("Discord event sync failed for event %v: %v", eventID, err)
+		}
+	}()
+}
+
+// Helper function to post or edit an event's announcement and scheduled event
+// Drafts are never posted, events that were posted keep their message so cancellations show up in place
+func syncDiscordEvent(ctx context.Context, eventID any) error {
+	discordEventSyncMu.Lock()
+	defer discordEventSyncMu.Unlock()
+
+	var event models.Event
+	var messageID, scheduledEventID string
+	err := db.Pool.QueryRow(ctx,
+		`SELECT id, title, description, date, end_date, room, external_link, status, COALESCE(cancel_reason, ''),
+		        discord_message_id, discord_scheduled_event_id
+		 FROM events WHERE id = $1`, eventID).
+		Scan(&event.ID, &event.Title, &event.Description, &event.Date, &event.EndDate, &event.Room, &event.ExternalLink,
+			&event.Status, &event.CancelReason, &messageID, &scheduledEventID)
+	if err != nil {
+		return err
+	}
+	if event.Status == models.EventDraft || (messageID == "" && event.Status != models.EventPublished) {
+		return nil
+	}
+
+	var errs []error
+	if discordEventsChannelID != "" || discordEventsWebhookURL != "" {
+		id, err := postDiscordAnnouncement(ctx, messageID, discordEventMessage(event))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("announcement: %w", err))
+		} else if id != messageID {
+			_, err = db.Pool.Exec(ctx, `UPDATE events SET discord_message_id = $1 WHERE id = $2`, id, eventID)
+			errs = append(errs, err)
+		}
+	}
+
+	if discordBotToken != "" && discordGuildID != "" {
+		id, err := upsertDiscordScheduledEvent(ctx, scheduledEventID, event)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scheduled event: %w", err))
+		} else if id != scheduledEventID {
+			_, err = db.Pool.Exec(ctx, `UPDATE events SET discord_scheduled_event_id = $1 WHERE id = $2`, id, eventID)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Helper function to build the announcement message for an event
+func discordEventMessage(event models.Event) map[string]any {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:3000"
+	}
+
+	// Discord renders <t:unix:F> in each reader's own timezone
+	fields := []map[string]any{
+		{"name": "When", "value": fmt.Sprintf("<t:%d:F> - <t:%d:t>", event.Date.Unix(), event.EndDate.Unix())},
+	}
+	if event.Room != "" {
+		fields = append(fields, map[string]any{"name": "Where", "value": event.Room, "inline": true})
+	}
+	if event.ExternalLink != "" {
+		fields = append(fields, map[string]any{"name": "Link", "value": event.ExternalLink, "inline": true})
+	}
+
+	title := event.Title
+	color := discordEmbedColor
+	if event.Status == models.EventCancelled {
+		title = "Cancelled: " + event.Title
+		color = discordEmbedCancelledColor
+		if event.CancelReason != "" {
+			fields = append(fields, map[string]any{"name": "Reason", "value": truncateRunes(event.CancelReason, 1024)})
+		}
+	}
+
+	return map[string]any{
+		// Announcements never ping anyone, even if the description mentions @everyone
+		"allowed_mentions": map[string]any{"parse": []string{}},
+		"embeds": []map[string]any{{
+			"title":       truncateRunes(title, 256),
+			"description": truncateRunes(event.Description, 4096),
+			"url":         frontendURL + "/dashboard/events",
+			"color":       color,
+			"fields":      fields,
+		}},
+	}
+}
+
+// Helper function to post a new announcement or edit the existing one, returns the message ID
+// A message deleted by hand in Discord is posted again
+func postDiscordAnnouncement(ctx context.Context, messageID string, message map[string]any) (string, error) {
+	var postURL, editURL, authorization string
+	if discordEventsChannelID != "" && discordBotToken != "" {
+		postURL = fmt.Sprintf("%s/channels/%s/messages", discordProvider.apiURL, discordEventsChannelID)
+		editURL = postURL + "/" + messageID
+		authorization = "Bot " + discordBotToken
+	} else if discordEventsWebhookURL != "" {
+		postURL = discordEventsWebhookURL + "?wait=true"
+		editURL = discordEventsWebhookURL + "/messages/" + messageID
+	} else {
+		return "", errors.New("DISCORD_EVENTS_CHANNEL_ID needs DISCORD_BOT_TOKEN")
+	}
+
+	if messageID != "" {
+		resp, err := discordRequest(ctx, "PATCH", editURL, authorization, message)
+		if err != nil {
+			return "", err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return messageID, nil
+		}
+		if resp.StatusCode != http.StatusNotFound {
+			return "", fmt.Errorf("Discord API returned %d", resp.StatusCode)
+		}
+	}
+
+	resp, err := discordRequest(ctx, "POST", postURL, authorization, message)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Discord API returned %d", resp.StatusCode)
+	}
+	var posted struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&posted); err != nil {
+		return "", err
+	}
+	return posted.ID, nil
+}
+
+// Helper function to create or update the Discord scheduled event for an event, returns its ID
+// Discord only accepts scheduled events that haven't started, past events are left alone
+func upsertDiscordScheduledEvent(ctx context.Context, scheduledEventID string, event models.Event) (string, error) {
+	path := fmt.Sprintf("/guilds/%s/scheduled-events", discordGuildID)
+
+	if event.Status == models.EventCancelled {
+		if scheduledEventID == "" {
+			return "", nil
+		}
+		resp, err := discordBotRequest(ctx, "PATCH", path+"/"+scheduledEventID,
+			map[string]any{"status": discordScheduledEventCanceled})
+		if err != nil {
+			return scheduledEventID, err
+		}
+		resp.Body.Close()
+		// 404 means it was deleted by hand, 400 that it already started or ended
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusBadRequest {
+			return scheduledEventID, fmt.Errorf("Discord API returned %d", resp.StatusCode)
+		}
+		return scheduledEventID, nil
+	}
+	if !event.Date.After(time.Now()) {
+		return scheduledEventID, nil
+	}
+
+	location := event.Room
+	if location == "" {
+		location = event.ExternalLink
+	}
+	if location == "" {
+		location = "TBA"
+	}
+	payload := map[string]any{
+		"name":                 truncateRunes(event.Title, 100),
+		"description":          truncateRunes(event.Description, 1000),
+		"scheduled_start_time": event.Date.UTC().Format(time.RFC3339),
+		"scheduled_end_time":   event.EndDate.UTC().Format(time.RFC3339),
+		"privacy_level":        discordGuildOnly,
+		"entity_type":          discordScheduledEventExternal,
+		"entity_metadata":      map[string]any{"location": truncateRunes(location, 100)},
+	}
+
+	if scheduledEventID != "" {
+		resp, err := discordBotRequest(ctx, "PATCH", path+"/"+scheduledEventID, payload)
+		if err != nil {
+			return scheduledEventID, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return scheduledEventID, nil
+		}
+		if resp.StatusCode != http.StatusNotFound {
+			return scheduledEventID, fmt.Errorf("Discord API returned %d", resp.StatusCode)
+		}
+	}
+
+	resp, err := discordBotRequest(ctx, "POST", path, payload)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Discord API returned %d", resp.StatusCode)
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// Helper function to cut text to Discord's length limits without splitting a character
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+func StartDiscordRSVPScheduler() {
+	/*
+		Starts a goroutine that treats "interested" on a Discord scheduled event as an RSVP
+		Only runs when DISCORD_RSVP_SYNC=true and the bot is configured
+		Linked members who mark an upcoming event as interested are registered (or waitlisted)
+		Registrations made this way are removed again if the member is no longer interested
+		Members who unregistered on the platform are never registered again
+	*/
+	if !discordRSVPSyncEnabled {
+		return
+	}
+	if discordBotToken == "" || discordGuildID == "" {
+		log.Println("Discord RSVP sync disabled, DISCORD_BOT_TOKEN or DISCORD_GUILD_ID not set")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(discordRSVPSyncInterval)
+		defer ticker.Stop()
+		for {
+			runDiscordRSVPSync()
+			<-ticker.C
+		}
+	}()
+}
+
+func runDiscordRSVPSync() {
+	ctx := context.Background()
+	rows, err := db.Pool.Query(ctx,
+		`SELECT id, discord_scheduled_event_id FROM events
+		 WHERE status = $1 AND discord_scheduled_event_id <> '' AND date > NOW()`,
+		models.EventPublished)
+	if err != nil {
+		log.Println("Discord RSVP sync error: ", err)
+		return
+	}
+	type scheduled struct {
+		eventID          int
+		scheduledEventID string
+	}
+	events := []scheduled{}
+	for rows.Next() {
+		var e scheduled
+		if err := rows.Scan(&e.eventID, &e.scheduledEventID); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+
+	for _, e := range events {
+		if err := syncDiscordRSVPs(ctx, e.eventID, e.scheduledEventID); err != nil {
+			log.Printf("Discord RSVP sync failed for event %d: %v", e.eventID, err)
+		}
+	}
+}
+
+// Helper function to list the Discord users interested in a scheduled event
+func discordInterestedUsers(ctx context.Context, scheduledEventID string) ([]string, error) {
+	ids := []string{}
+	after := ""
+	for {
+		path := fmt.Sprintf("/guilds/%s/scheduled-events/%s/users?limit=100", discordGuildID, scheduledEventID)
+		if after != "" {
+			path += "&after=" + after
+		}
+		resp, err := discordBotRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("Discord API returned %d", resp.StatusCode)
+		}
+		var page []struct {
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range page {
+			ids = append(ids, entry.User.ID)
+		}
+		if len(page) < 100 {
+			return ids, nil
+		}
+		after = page[len(page)-1].User.ID
+	}
+}
+
+// Helper function to bring one event's Discord registrations in line with who is interested
+func syncDiscordRSVPs(ctx context.Context, eventID int, scheduledEventID string) error {
+	discordIDs, err := discordInterestedUsers(ctx, scheduledEventID)
+	if err != nil {
+		return err
+	}
+
+	// Only members who linked Discord can be matched to an account
+	rows, err := db.Pool.Query(ctx,
+		`SELECT user_id FROM discord_integrations WHERE discord_id = ANY($1)`, discordIDs)
+	if err != nil {
+		return err
+	}
+	interested, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return err
+	}
+
+	// Members who unregistered on the platform keep that choice, losing interest on Discord can be undone
+	rows, err = db.Pool.Query(ctx,
+		`SELECT user_id FROM event_registration_withdrawals WHERE event_id = $1 AND source <> $2`,
+		eventID, models.RegistrationSourceDiscord)
+	if err != nil {
+		return err
+	}
+	withdrawn, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return err
+	}
+	withdrewOnPlatform := map[int]bool{}
+	for _, userID := range withdrawn {
+		withdrewOnPlatform[userID] = true
+	}
+
+	rows, err = db.Pool.Query(ctx,
+		`SELECT user_id, source FROM event_registrations WHERE event_id = $1`, eventID)
+	if err != nil {
+		return err
+	}
+	registered := map[int]string{}
+	for rows.Next() {
+		var userID int
+		var source string
+		if err := rows.Scan(&userID, &source); err != nil {
+			rows.Close()
+			return err
+		}
+		registered[userID] = source
+	}
+	rows.Close()
+
+	stillInterested := map[int]bool{}
+	for _, userID := range interested {
+		stillInterested[userID] = true
+		if _, ok := registered[userID]; !ok && !withdrewOnPlatform[userID] {
+			if err := registerFromDiscord(ctx, eventID, userID); err != nil {
+				log.Printf("Discord RSVP for user %d failed: %v", userID, err)
+			}
+		}
+	}
+
+	// Registrations made on the platform are never removed from here
+	for userID, source := range registered {
+		if source == models.RegistrationSourceDiscord && !stillInterested[userID] {
+			if err := unregisterFromDiscord(ctx, eventID, userID); err != nil {
+				log.Printf("Discord RSVP removal for user %d failed: %v", userID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Helper function to register a member who marked the Discord scheduled event as interested
+func registerFromDiscord(ctx context.Context, eventID, userID int) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	var capacity int
+	err = tx.QueryRow(ctx,
+		"SELECT status, capacity FROM events WHERE id = $1 FOR UPDATE", eventID).Scan(&status, &capacity)
+	if err != nil {
+		return err
+	}
+	if status != models.EventPublished {
+		return nil
+	}
+
+	// Never re-register members who unregistered on the platform
+	var exists bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM event_registrations WHERE event_id = $1 AND user_id = $2)
+		     OR EXISTS(SELECT 1 FROM event_registration_withdrawals WHERE event_id = $1 AND user_id = $2 AND source <> $3)`,
+		eventID, userID, models.RegistrationSourceDiscord).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	registrationStatus, err := addRegistration(tx, eventID, userID, capacity, models.RegistrationSourceDiscord)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	queueDiscordRoleSync(userID)
+	kind := notifications.KindConfirmation
+	if registrationStatus == models.RegistrationWaitlisted {
+		kind = notifications.KindWaitlisted
+	}
+	go notifyRegistrant(userID, strconv.Itoa(eventID), kind, "")
+	return nil
+}
+
+// Helper function to drop a Discord registration after the member lost interest
+func unregisterFromDiscord(ctx context.Context, eventID, userID int) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	err = tx.QueryRow(ctx, "SELECT status FROM events WHERE id = $1 FOR UPDATE", eventID).Scan(&status)
+	if err != nil {
+		return err
+	}
+
+	promotedUserID, err := removeRegistration(tx, eventID, userID, status, models.RegistrationSourceDiscord)
+	if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errRegistrationClosed) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	queueDiscordRoleSync(userID)
+	if promotedUserID > 0 {
+		queueDiscordRoleSync(promotedUserID)
+		go notifyRegistrant(promotedUserID, strconv.Itoa(eventID), notifications.KindWaitlistPromotion, "")
+	}
+	return nil
+}
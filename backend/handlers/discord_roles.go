@@ -0,0 +1,587 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// How often every linked member's roles are reconciled, fixes roles changed by hand in Discord
+	defaultDiscordRoleSyncHours = 6
+	// Attempts per bot request when Discord rate limits us
+	discordRateLimitRetries = 3
+)
+
+var discordSnowflakePattern = regexp.MustCompile(`^[0-9]{5,25}$`)
+
+var discordBotHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// Helper function to call the Discord API as the bot, payload is sent as JSON when not nil
+func discordBotRequest(ctx context.Context, method, path string, payload any) (*http.Response, error) {
+	if discordBotToken == "" || discordGuildID == "" {
+		return nil, errors.New("DISCORD_BOT_TOKEN or DISCORD_GUILD_ID not set")
+	}
+	return discordRequest(ctx, method, discordProvider.apiURL+path, "Bot "+discordBotToken, payload)
+}
+
+// Helper function to call the Discord API or a webhook
+// Waits out 429 responses using Discord's retry_after so bulk jobs can run through every member
+func discordRequest(ctx context.Context, method, url, authorization string, payload any) (*http.Response, error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := discordBotHTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || attempt == discordRateLimitRetries {
+			return resp, nil
+		}
+
+		var limit struct {
+			RetryAfter float64 `json:"retry_after"`
+		}
+		json.NewDecoder(resp.Body).Decode(&limit)
+		resp.Body.Close()
+		wait := time.Duration(limit.RetryAfter * float64(time.Second))
+		if wait <= 0 {
+			wait = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Helper function to load every role mapping
+func loadDiscordRoleMappings(ctx context.Context) ([]models.DiscordRoleMapping, error) {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT m.id, m.attribute, m.value, m.role_id, m.created_at,
+		        COALESCE(s.name, e.title, m.value)
+		 FROM discord_role_mappings m
+		 LEFT JOIN schools s ON m.attribute = 'school' AND s.id::text = m.value
+		 LEFT JOIN events e ON m.attribute = 'event' AND e.id::text = m.value
+		 ORDER BY m.attribute, m.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mappings := []models.DiscordRoleMapping{}
+	for rows.Next() {
+		var m models.DiscordRoleMapping
+		if err := rows.Scan(&m.ID, &m.Attribute, &m.Value, &m.RoleID, &m.CreatedAt, &m.Label); err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
+
+// Helper function to work out which platform attributes a member has, as "attribute:value" keys
+func memberRoleAttributes(ctx context.Context, userID int) (map[string]bool, error) {
+	attributes := map[string]bool{models.RoleAttributeVerified + ":": true}
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT eh.school_id, COALESCE(s.system, ''), eh.end_date
+		 FROM education_history eh
+		 LEFT JOIN schools s ON s.id = eh.school_id
+		 WHERE eh.user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	var latest time.Time
+	hasEducation, studying := false, false
+	for rows.Next() {
+		var schoolID *int
+		var system, endDate string
+		if err := rows.Scan(&schoolID, &system, &endDate); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if schoolID != nil {
+			attributes[models.RoleAttributeSchool+":"+strconv.Itoa(*schoolID)] = true
+		}
+		if system != "" {
+			attributes[models.RoleAttributeSchoolSystem+":"+system] = true
+		}
+
+		hasEducation = true
+		graduated, ok := graduationDate(endDate)
+		if !ok {
+			studying = true
+		} else if graduated.After(latest) {
+			latest = graduated
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if hasEducation && !studying && latest.Before(time.Now()) {
+		attributes[models.RoleAttributeAlumni+":"] = true
+	}
+
+	// Event roles last until the event is over or cancelled
+	eventIDs, err := db.Pool.Query(ctx,
+		`SELECT er.event_id FROM event_registrations er
+		 JOIN events e ON e.id = er.event_id
+		 WHERE er.user_id = $1 AND er.status = $2 AND e.status = $3`,
+		userID, models.RegistrationActive, models.EventPublished)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(eventIDs, pgx.RowTo[int])
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		attributes[models.RoleAttributeEvent+":"+strconv.Itoa(id)] = true
+	}
+
+	return attributes, nil
+}
+
+// Helper function to parse an education end date like "May 2024"
+// Returns false for ongoing studies (empty or "Present"), a bare year counts as the end of that year
+func graduationDate(endDate string) (time.Time, bool) {
+	endDate = strings.TrimSpace(endDate)
+	if endDate == "" || strings.EqualFold(endDate, "present") {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{"January 2006", "Jan 2006", "2006-01-02", "2006-01"} {
+		if t, err := time.Parse(layout, endDate); err == nil {
+			// Count the whole month so a May graduation turns into alumni in June
+			return t.AddDate(0, 1, 0), true
+		}
+	}
+	if year, err := strconv.Atoi(endDate); err == nil {
+		return time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC), true
+	}
+	return time.Time{}, false
+}
+
+// Helper function to bring one member's mapped roles in line with their platform data
+// Only roles that appear in a mapping are touched, anything else set in Discord is left alone
+func syncDiscordRoles(ctx context.Context, userID int, mappings []models.DiscordRoleMapping) error {
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	var discordID string
+	err := db.Pool.QueryRow(ctx,
+		`SELECT discord_id FROM discord_integrations WHERE user_id = $1`, userID).Scan(&discordID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	syncErr := applyDiscordRoles(ctx, userID, discordID, mappings)
+
+	errText := ""
+	if syncErr != nil {
+		errText = syncErr.Error()
+	}
+	_, err = db.Pool.Exec(ctx,
+		`UPDATE discord_integrations SET roles_synced_at = NOW(), role_sync_error = $1 WHERE user_id = $2`,
+		errText, userID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+	}
+	return syncErr
+}
+
+func applyDiscordRoles(ctx context.Context, userID int, discordID string, mappings []models.DiscordRoleMapping) error {
+	resp, err := discordBotRequest(ctx, "GET", fmt.Sprintf("/guilds/%s/members/%s", discordGuildID, discordID), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Members who left the server lose their verification, they get roles again once they rejoin
+	if resp.StatusCode == http.StatusNotFound {
+		_, err := db.Pool.Exec(ctx,
+			`UPDATE discord_integrations SET verified = false, last_verified_at = NOW() WHERE user_id = $1`, userID)
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Discord API returned %d", resp.StatusCode)
+	}
+
+	var member struct {
+		Roles []string `json:"roles"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&member); err != nil {
+		return err
+	}
+	current := map[string]bool{}
+	for _, role := range member.Roles {
+		current[role] = true
+	}
+
+	attributes, err := memberRoleAttributes(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// The same role can be mapped from several attributes, the member keeps it if any one applies
+	desired := map[string]bool{}
+	managed := map[string]bool{}
+	for _, m := range mappings {
+		managed[m.RoleID] = true
+		if attributes[m.Attribute+":"+m.Value] {
+			desired[m.RoleID] = true
+		}
+	}
+
+	_, err = db.Pool.Exec(ctx,
+		`UPDATE discord_integrations SET verified = true, last_verified_at = NOW() WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	for role := range managed {
+		method := ""
+		switch {
+		case desired[role] && !current[role]:
+			method = "PUT"
+		case !desired[role] && current[role]:
+			method = "DELETE"
+		default:
+			continue
+		}
+		if err := setDiscordRole(ctx, method, discordID, role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to add (PUT) or remove (DELETE) one role on a guild member
+func setDiscordRole(ctx context.Context, method, discordID, roleID string) error {
+	resp, err := discordBotRequest(ctx, method,
+		fmt.Sprintf("/guilds/%s/members/%s/roles/%s", discordGuildID, discordID, roleID), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("Discord API returned %d for role %s", resp.StatusCode, roleID)
+	}
+	return nil
+}
+
+// Helper function to take every mapped role off a member who unlinked their account
+func clearDiscordRoles(discordID string) {
+	if discordBotToken == "" || discordGuildID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	mappings, err := loadDiscordRoleMappings(ctx)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return
+	}
+	removed := map[string]bool{}
+	for _, m := range mappings {
+		if removed[m.RoleID] {
+			continue
+		}
+		removed[m.RoleID] = true
+		if err := setDiscordRole(ctx, "DELETE", discordID, m.RoleID); err != nil {
+			log.Printf("Failed to remove Discord role %s: %v", m.RoleID, err)
+		}
+	}
+}
+
+// Helper function to resync a member's roles after their platform data changed
+// Runs in the background so requests don't wait on Discord, the reconciliation job catches failures
+func queueDiscordRoleSync(userID int) {
+	if discordBotToken == "" || discordGuildID == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		mappings, err := loadDiscordRoleMappings(ctx)
+		if err != nil {
+			log.Println("Internal DB Error: ", err)
+			return
+		}
+		if err := syncDiscordRoles(ctx, userID, mappings); err != nil {
+			log.Printf("Discord role sync failed for user %d: %v", userID, err)
+		}
+	}()
+}
+
+func StartDiscordRoleSyncScheduler() {
+	/*
+		Starts a goroutine that reconciles every linked member's Discord roles
+		Runs every DISCORD_ROLE_SYNC_HOURS hours (default 6) to fix drift from manual role edits
+	*/
+	if discordBotToken == "" || discordGuildID == "" {
+		log.Println("Discord role sync disabled, DISCORD_BOT_TOKEN or DISCORD_GUILD_ID not set")
+		return
+	}
+
+	interval := time.Duration(envInt("DISCORD_ROLE_SYNC_HOURS", defaultDiscordRoleSyncHours)) * time.Hour
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			reconcileDiscordRoles()
+			<-ticker.C
+		}
+	}()
+}
+
+// Guards against the scheduler and an admin-triggered run overlapping
+var discordReconcileRunning = make(chan struct{}, 1)
+
+func reconcileDiscordRoles() {
+	select {
+	case discordReconcileRunning <- struct{}{}:
+		defer func() { <-discordReconcileRunning }()
+	default:
+		return
+	}
+
+	ctx := context.Background()
+	mappings, err := loadDiscordRoleMappings(ctx)
+	if err != nil {
+		log.Println("Discord role sync error: ", err)
+		return
+	}
+	if len(mappings) == 0 {
+		return
+	}
+
+	rows, err := db.Pool.Query(ctx, `SELECT user_id FROM discord_integrations ORDER BY roles_synced_at NULLS FIRST`)
+	if err != nil {
+		log.Println("Discord role sync error: ", err)
+		return
+	}
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		log.Println("Discord role sync error: ", err)
+		return
+	}
+
+	failed := 0
+	for _, userID := range userIDs {
+		if err := syncDiscordRoles(ctx, userID, mappings); err != nil {
+			log.Printf("Discord role sync failed for user %d: %v", userID, err)
+			failed++
+		}
+	}
+	log.Printf("Discord roles reconciled for %d members, %d failed\n", len(userIDs)-failed, failed)
+}
+
+// GET /api/admin/discord/roles (ADMIN ONLY)
+func GetDiscordRoleMappings(c *fiber.Ctx) error {
+	/*
+		Gets the role mappings along with the server's roles to pick from
+		Roles are empty if the bot isn't configured or Discord can't be reached
+	*/
+	mappings, err := loadDiscordRoleMappings(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+
+	roles := []models.DiscordRole{}
+	resp, err := discordBotRequest(context.Background(), "GET", fmt.Sprintf("/guilds/%s/roles", discordGuildID), nil)
+	if err != nil {
+		log.Println("Failed to list Discord roles: ", err)
+	} else {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&roles); err != nil {
+				log.Println("Failed to decode Discord roles: ", err)
+			}
+		} else {
+			log.Printf("Failed to list Discord roles: Discord API returned %d", resp.StatusCode)
+		}
+	}
+
+	return c.JSON(fiber.Map{"mappings": mappings, "roles": roles})
+}
+
+// POST /api/admin/discord/roles (ADMIN ONLY)
+// Body: { "attribute": "school", "value": "12", "role_id": "1234567890" }
+func SaveDiscordRoleMapping(c *fiber.Ctx) error {
+	/*
+		Maps a platform attribute to a Discord role, replacing the role if the attribute is already mapped
+		verified and alumni take no value, school takes a school ID, school_system CUNY or SUNY and event an event ID
+		Members are resynced in the background
+	*/
+	var body struct {
+		Attribute string `json:"attribute"`
+		Value     string `json:"value"`
+		RoleID    string `json:"role_id"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	body.Attribute = strings.TrimSpace(body.Attribute)
+	body.Value = strings.TrimSpace(body.Value)
+	body.RoleID = strings.TrimSpace(body.RoleID)
+
+	if !discordSnowflakePattern.MatchString(body.RoleID) {
+		return c.Status(400).JSON(fiber.Map{"error": "role_id must be a Discord role ID"})
+	}
+
+	var exists bool
+	var err error
+	switch body.Attribute {
+	case models.RoleAttributeVerified, models.RoleAttributeAlumni:
+		body.Value = ""
+		exists = true
+	case models.RoleAttributeSchoolSystem:
+		body.Value = strings.ToUpper(body.Value)
+		exists = body.Value == models.SchoolSystemCUNY || body.Value == models.SchoolSystemSUNY
+	case models.RoleAttributeSchool:
+		err = db.Pool.QueryRow(context.Background(),
+			`SELECT EXISTS(SELECT 1 FROM schools WHERE id::text = $1)`, body.Value).Scan(&exists)
+	case models.RoleAttributeEvent:
+		err = db.Pool.QueryRow(context.Background(),
+			`SELECT EXISTS(SELECT 1 FROM events WHERE id::text = $1)`, body.Value).Scan(&exists)
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "attribute must be one of verified, school, school_system, alumni, event"})
+	}
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	if !exists {
+		return c.Status(400).JSON(fiber.Map{"error": "Unknown " + body.Attribute + " " + body.Value})
+	}
+
+	var id int
+	err = db.Pool.QueryRow(context.Background(),
+		`INSERT INTO discord_role_mappings (attribute, value, role_id) VALUES ($1, $2, $3)
+		 ON CONFLICT (attribute, value) DO UPDATE SET role_id = EXCLUDED.role_id
+		 RETURNING id`,
+		body.Attribute, body.Value, body.RoleID).Scan(&id)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+	}
+
+	go reconcileDiscordRoles()
+
+	return c.JSON(fiber.Map{"message": "Role mapping saved", "id": id})
+}
+
+// DELETE /api/admin/discord/roles/:id (ADMIN ONLY)
+func DeleteDiscordRoleMapping(c *fiber.Ctx) error {
+	/*
+		Removes a role mapping
+		The bot stops managing the role, members who have it keep it unless ?strip=true is passed
+	*/
+	var roleID string
+	err := db.Pool.QueryRow(context.Background(),
+		`DELETE FROM discord_role_mappings WHERE id = $1 RETURNING role_id`, c.Params("id")).Scan(&roleID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.Status(404).JSON(fiber.Map{"error": "Role mapping not found"})
+	}
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database delete failed"})
+	}
+
+	if c.QueryBool("strip") {
+		go stripDiscordRole(roleID)
+	}
+
+	return c.JSON(fiber.Map{"message": "Role mapping deleted"})
+}
+
+// Helper function to take a role off every linked member, unless another mapping still uses it
+func stripDiscordRole(roleID string) {
+	ctx := context.Background()
+
+	var stillMapped bool
+	err := db.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM discord_role_mappings WHERE role_id = $1)`, roleID).Scan(&stillMapped)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return
+	}
+	if stillMapped {
+		go reconcileDiscordRoles()
+		return
+	}
+
+	rows, err := db.Pool.Query(ctx, `SELECT discord_id FROM discord_integrations`)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return
+	}
+	discordIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return
+	}
+	for _, discordID := range discordIDs {
+		if err := setDiscordRole(ctx, "DELETE", discordID, roleID); err != nil {
+			log.Printf("Failed to remove Discord role %s: %v", roleID, err)
+		}
+	}
+}
+
+// POST /api/admin/discord/roles/sync (ADMIN ONLY)
+func SyncDiscordRoles(c *fiber.Ctx) error {
+	/*
+		Starts reconciling every linked member's roles now instead of waiting for the scheduler
+	*/
+	if discordBotToken == "" || discordGuildID == "" {
+		return c.Status(503).JSON(fiber.Map{"error": "Discord bot is not configured"})
+	}
+
+	go reconcileDiscordRoles()
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "Role sync started"})
+}
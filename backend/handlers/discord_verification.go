@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+)
+
+const (
+	// How often every linked member's guild membership is rechecked
+	defaultDiscordVerifyHours = 24
+	// Members loaded and checked per batch
+	defaultDiscordVerifyBatchSize = 50
+)
+
+// Helper function to ask Discord whether a user is in the guild
+// Also returns how long to wait before the next bot request so bulk checks stay inside the rate limit
+func checkDiscordMembership(ctx context.Context, discordID string) (bool, time.Duration, error) {
+	resp, err := discordBotRequest(ctx, "GET", fmt.Sprintf("/guilds/%s/members/%s", discordGuildID, discordID), nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	pause := discordRateLimitPause(resp.Header)
+	// 200 = user is in the server, 404 = user is not in the server
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, pause, nil
+	case http.StatusNotFound:
+		return false, pause, nil
+	default:
+		return false, pause, fmt.Errorf("Discord API returned %d", resp.StatusCode)
+	}
+}
+
+// Helper function to read Discord's X-RateLimit-* headers
+// Returns how long until the bucket resets once it has no requests left, zero otherwise
+func discordRateLimitPause(header http.Header) time.Duration {
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return 0
+	}
+	resetAfter, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset-After"), 64)
+	if err != nil || resetAfter <= 0 {
+		return time.Second
+	}
+	return time.Duration(resetAfter * float64(time.Second))
+}
+
+func StartDiscordVerificationScheduler() {
+	/*
+		Starts a goroutine that rechecks every linked member's guild membership
+		Runs every DISCORD_VERIFY_HOURS hours (default 24), members who left the server lose their verification
+		Members are checked DISCORD_VERIFY_BATCH_SIZE at a time (default 50), least recently verified first
+	*/
+	if discordBotToken == "" || discordGuildID == "" {
+		log.Println("Discord re-verification disabled, DISCORD_BOT_TOKEN or DISCORD_GUILD_ID not set")
+		return
+	}
+
+	interval := time.Duration(envInt("DISCORD_VERIFY_HOURS", defaultDiscordVerifyHours)) * time.Hour
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			reverifyDiscordMembers()
+			<-ticker.C
+		}
+	}()
+}
+
+// Guards against a slow run overlapping the next tick
+var discordVerifyRunning = make(chan struct{}, 1)
+
+func reverifyDiscordMembers() {
+	select {
+	case discordVerifyRunning <- struct{}{}:
+		defer func() { <-discordVerifyRunning }()
+	default:
+		return
+	}
+
+	ctx := context.Background()
+	batchSize := envInt("DISCORD_VERIFY_BATCH_SIZE", defaultDiscordVerifyBatchSize)
+
+	// Rows are stamped with the database's NOW(), so the run starts on the database clock too
+	var started time.Time
+	if err := db.Pool.QueryRow(ctx, "SELECT NOW()").Scan(&started); err != nil {
+		log.Println("Discord re-verification error: ", err)
+		return
+	}
+
+	// Checked members drop out of the query through last_verified_at, failed ones are skipped by ID
+	failed := []int{}
+	checked, changed := 0, 0
+	for {
+		rows, err := db.Pool.Query(ctx,
+			`SELECT user_id, discord_id, verified FROM discord_integrations
+			 WHERE (last_verified_at IS NULL OR last_verified_at < $1) AND NOT (user_id = ANY($2))
+			 ORDER BY last_verified_at NULLS FIRST, user_id
+			 LIMIT $3`,
+			started, failed, batchSize)
+		if err != nil {
+			log.Println("Discord re-verification error: ", err)
+			return
+		}
+		type member struct {
+			userID    int
+			discordID string
+			verified  bool
+		}
+		batch := []member{}
+		for rows.Next() {
+			var m member
+			if err := rows.Scan(&m.userID, &m.discordID, &m.verified); err != nil {
+				rows.Close()
+				log.Println("Discord re-verification error: ", err)
+				return
+			}
+			batch = append(batch, m)
+		}
+		rows.Close()
+		if len(batch) == 0 {
+			break
+		}
+
+		batchFailed := 0
+		for _, m := range batch {
+			inGuild, pause, err := checkDiscordMembership(ctx, m.discordID)
+			if err != nil {
+				log.Printf("Discord re-verification failed for user %d: %v", m.userID, err)
+				failed = append(failed, m.userID)
+				batchFailed++
+			} else {
+				_, err = db.Pool.Exec(ctx,
+					`UPDATE discord_integrations SET verified = $1, last_verified_at = NOW() WHERE user_id = $2`,
+					inGuild, m.userID)
+				if err != nil {
+					log.Println("Internal DB Error: ", err)
+					failed = append(failed, m.userID)
+				} else {
+					checked++
+					if inGuild != m.verified {
+						changed++
+						// Members who rejoined get their mapped roles back
+						if inGuild {
+							queueDiscordRoleSync(m.userID)
+						}
+					}
+				}
+			}
+
+			if pause > 0 {
+				time.Sleep(pause)
+			}
+		}
+
+		// Every check failing means Discord or the bot token is the problem, try again next run
+		if batchFailed == len(batch) {
+			log.Println("Discord re-verification stopped, a whole batch failed")
+			break
+		}
+	}
+
+	log.Printf("Discord membership rechecked for %d members, %d changed, %d failed\n", checked, changed, len(failed))
+}
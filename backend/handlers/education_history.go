@@ -34,7 +34,9 @@ func GetEducationHistory(c *fiber.Ctx) error {
 
 	// Query the database for all education history
 	rows, err := db.Pool.Query(context.Background(),
-		`SELECT id, user_id, school_name, school_logo_url, degree, field_of_study, start_date, end_date, location, description, created_at 
+		`SELECT id, user_id, school_id, school_name, school_logo_url,
+			COALESCE((SELECT s.domain FROM schools s WHERE s.id = education_history.school_id), ''),
+			degree, field_of_study, start_date, end_date, location, description, created_at 
 		FROM education_history WHERE user_id = $1 ORDER BY created_at DESC`, claims.UserID)
 
 	if err != nil {
@@ -46,7 +48,8 @@ func GetEducationHistory(c *fiber.Ctx) error {
 	educationHistory := []models.EducationHistory{}
 	for rows.Next() {
 		var education models.EducationHistory
-		rows.Scan(&education.ID, &education.UserID, &education.SchoolName, &education.SchoolLogoURL, &education.Degree, &education.FieldOfStudy, &education.StartDate, &education.EndDate, &education.Location, &education.Description, &education.CreatedAt)
+		rows.Scan(&education.ID, &education.UserID, &education.SchoolID, &education.SchoolName, &education.SchoolLogoURL, &education.SchoolDomain, &education.Degree, &education.FieldOfStudy, &education.StartDate, &education.EndDate, &education.Location, &education.Description, &education.CreatedAt)
+		education.SchoolLogoURL = logoURL(c, education.SchoolLogoURL, education.SchoolDomain, education.SchoolName)
 		educationHistory = append(educationHistory, education)
 	}
 
@@ -76,22 +79,35 @@ func AddEducationHistory(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body/JSON"})
 	}
 
-	// Auto-generate school logo URL if not provided
+	if schoolKey(body.SchoolName) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "School name is required"})
+	}
+
+	// Match the school against the registry so members can be grouped by school
+	school, err := resolveSchool(body.SchoolName)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+
+	// Use the registry logo if not provided
 	schoolLogo := body.SchoolLogoURL
-	if schoolLogo == "" && body.SchoolName != "" {
-		schoolLogo = getSchoolLogoURL(body.SchoolName)
+	if schoolLogo == "" {
+		schoolLogo = school.LogoURL
 	}
 
 	// Insert the education history into the database
 	_, err = db.Pool.Exec(context.Background(),
-		`INSERT INTO education_history (user_id, school_name, school_logo_url, degree, field_of_study, start_date, end_date, location, description)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-		claims.UserID, body.SchoolName, schoolLogo, body.Degree, body.FieldOfStudy,
+		`INSERT INTO education_history (user_id, school_id, school_name, school_logo_url, degree, field_of_study, start_date, end_date, location, description)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		claims.UserID, school.ID, school.Name, schoolLogo, body.Degree, body.FieldOfStudy,
 		body.StartDate, body.EndDate, body.Location, body.Description)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database insert failed: " + err.Error()})
 	}
 
+	queueDiscordRoleSync(claims.UserID)
+
 	return c.JSON(fiber.Map{"message": "Education history added successfully"})
 }
 
@@ -125,15 +141,24 @@ func UpdateEducationHistory(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
 	}
 
-	// Generate school logo URL using logo.dev
-	schoolLogo := getSchoolLogoURL(body.SchoolName)
+	if schoolKey(body.SchoolName) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "School name is required"})
+	}
+
+	// Match the school against the registry so members can be grouped by school
+	school, err := resolveSchool(body.SchoolName)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	schoolLogo := school.LogoURL
 
 	// Update the education history in the database
 	result, err := db.Pool.Exec(context.Background(),
 		`UPDATE education_history 
-		SET school_name=$1, school_logo_url=$2, degree=$3, field_of_study=$4, start_date=$5, end_date=$6, location=$7, description=$8
-		WHERE id=$9 AND user_id=$10`,
-		body.SchoolName, schoolLogo, body.Degree, body.FieldOfStudy, body.StartDate, body.EndDate,
+		SET school_id=$1, school_name=$2, school_logo_url=$3, degree=$4, field_of_study=$5, start_date=$6, end_date=$7, location=$8, description=$9
+		WHERE id=$10 AND user_id=$11`,
+		school.ID, school.Name, schoolLogo, body.Degree, body.FieldOfStudy, body.StartDate, body.EndDate,
 		body.Location, body.Description, id, claims.UserID)
 
 	if err != nil {
@@ -146,6 +171,8 @@ func UpdateEducationHistory(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Education history not found or unauthorized"})
 	}
 
+	queueDiscordRoleSync(claims.UserID)
+
 	return c.JSON(fiber.Map{"message": "Education history updated successfully"})
 }
 
@@ -189,5 +216,7 @@ func DeleteEducationHistory(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Education history not found or unauthorized"})
 	}
 
+	queueDiscordRoleSync(claims.UserID)
+
 	return c.JSON(fiber.Map{"message": "Education history deleted successfully"})
 }
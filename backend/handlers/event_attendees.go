@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/notifications"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GET /api/admin/events/:id/attendees/export (ADMIN ONLY)
+func ExportEventAttendees(c *fiber.Ctx) error {
+	/*
+		Exports every registrant of an event as a CSV file
+		Columns: name, email, school, graduation year, check-in status, registration time
+	*/
+	eventID := c.Params("id")
+
+	var title string
+	err := db.Pool.QueryRow(context.Background(),
+		"SELECT title FROM events WHERE id = $1", eventID).Scan(&title)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
+	}
+
+	// Graduation year is the latest four digit year in the member's education end dates
+	rows, err := db.Pool.Query(context.Background(), `
+		SELECT u.name, u.email,
+			COALESCE(
+				NULLIF(u.school, ''),
+				(SELECT STRING_AGG(DISTINCT eh.school_name, ', ' ORDER BY eh.school_name)
+				 FROM education_history eh WHERE eh.user_id = u.id),
+				''
+			) AS schools,
+			COALESCE(
+				(SELECT MAX(SUBSTRING(eh.end_date FROM '[0-9]{4}'))
+				 FROM education_history eh WHERE eh.user_id = u.id),
+				''
+			) AS graduation_year,
+			er.status, er.checked_in_at, er.registered_at
+		FROM event_registrations er
+		JOIN users u ON u.id = er.user_id
+		WHERE er.event_id = $1
+		ORDER BY er.registered_at`, eventID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"name", "email", "school", "graduation_year", "registration_status", "checked_in", "checked_in_at", "registered_at"})
+
+	for rows.Next() {
+		var name, email, school, graduationYear, status string
+		var checkedInAt *time.Time
+		var registeredAt time.Time
+		if err := rows.Scan(&name, &email, &school, &graduationYear, &status, &checkedInAt, &registeredAt); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+
+		checkedIn, checkedInTime := "no", ""
+		if checkedInAt != nil {
+			checkedIn, checkedInTime = "yes", checkedInAt.Format(time.RFC3339)
+		}
+		writer.Write([]string{name, email, school, graduationYear, status, checkedIn, checkedInTime, registeredAt.Format(time.RFC3339)})
+	}
+	writer.Flush()
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="event_%s_attendees.csv"`, eventID))
+	return c.Send(buf.Bytes())
+}
+
+// POST /api/events/:id/checkin/:userId (HOSTS AND ADMINS)
+func CheckInAttendee(c *fiber.Ctx) error {
+	/*
+		Marks a registered member as checked in at the event
+	*/
+	result, err := db.Pool.Exec(context.Background(),
+		`UPDATE event_registrations SET checked_in_at = NOW()
+		 WHERE event_id = $1 AND user_id = $2 AND status = $3 AND checked_in_at IS NULL`,
+		c.Params("id"), c.Params("userId"), models.RegistrationActive)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+
+	if result.RowsAffected() == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Member is not registered or already checked in"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Checked in successfully"})
+}
+
+// DELETE /api/events/:id/checkin/:userId (HOSTS AND ADMINS)
+func UndoCheckIn(c *fiber.Ctx) error {
+	/*
+		Clears a member's check-in, e.g. when the wrong person was checked in
+	*/
+	result, err := db.Pool.Exec(context.Background(),
+		`UPDATE event_registrations SET checked_in_at = NULL
+		 WHERE event_id = $1 AND user_id = $2 AND checked_in_at IS NOT NULL`,
+		c.Params("id"), c.Params("userId"))
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+
+	if result.RowsAffected() == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Member is not checked in"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Check-in removed"})
+}
+
+// POST /api/admin/events/:id/announce (ADMIN ONLY)
+// Body: { "subject": "Room change", "message": "We moved to room 204" }
+func AnnounceToEvent(c *fiber.Ctx) error {
+	/*
+		Sends an announcement to every active registrant of an event who hasn't opted out
+		Delivery happens in the background through the configured notification sender
+	*/
+	eventID := c.Params("id")
+
+	var body struct {
+		Subject string `json:"subject"`
+		Message string `json:"message"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+
+	if strings.TrimSpace(body.Subject) == "" || strings.TrimSpace(body.Message) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Subject and message are required"})
+	}
+
+	var title string
+	err := db.Pool.QueryRow(context.Background(),
+		"SELECT title FROM events WHERE id = $1", eventID).Scan(&title)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
+	}
+
+	rows, err := db.Pool.Query(context.Background(), `
+		SELECT u.email FROM event_registrations er
+		JOIN users u ON u.id = er.user_id
+		LEFT JOIN notification_preferences np ON np.user_id = u.id
+		WHERE er.event_id = $1 AND er.status = $2 AND u.email <> ''
+		  AND COALESCE(np.announcements, TRUE)`,
+		eventID, models.RegistrationActive)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+
+	recipients := []string{}
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		recipients = append(recipients, email)
+	}
+	rows.Close()
+
+	subject := fmt.Sprintf("[%s] %s", title, body.Subject)
+	go func() {
+		failed := 0
+		for _, email := range recipients {
+			err := notifications.Send(context.Background(), notifications.Message{
+				To:      email,
+				Subject: subject,
+				Body:    body.Message,
+			})
+			if err != nil {
+				failed++
+				log.Printf("Failed to send announcement for event %s: %v", eventID, err)
+			}
+		}
+		log.Printf("Announcement for event %s sent to %d/%d registrants", eventID, len(recipients)-failed, len(recipients))
+	}()
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":    "Announcement queued",
+		"recipients": len(recipients),
+	})
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GET /api/events/:id/hosts
+func GetEventHosts(c *fiber.Ctx) error {
+	/*
+		Gets the member hosts and external speakers for an event
+		Returns a JSON object with hosts and speakers arrays
+	*/
+	eventID := c.Params("id")
+
+	var status string
+	err := db.Pool.QueryRow(context.Background(),
+		"SELECT status FROM events WHERE id = $1", eventID).Scan(&status)
+	if err != nil || status == models.EventDraft {
+		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
+	}
+
+	rows, err := db.Pool.Query(context.Background(), `
+		SELECT eh.event_id, u.id, u.name, u.picture, eh.role, eh.added_at
+		FROM event_hosts eh
+		JOIN users u ON u.id = eh.user_id
+		WHERE eh.event_id = $1
+		ORDER BY eh.role DESC, u.name`, eventID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	defer rows.Close()
+
+	hosts := []models.EventHost{}
+	for rows.Next() {
+		var host models.EventHost
+		if err := rows.Scan(&host.EventID, &host.UserID, &host.Name, &host.Picture, &host.Role, &host.AddedAt); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+
+	speakerRows, err := db.Pool.Query(context.Background(), `
+		SELECT id, event_id, name, bio, photo_url, links, position
+		FROM event_speakers WHERE event_id = $1
+		ORDER BY position, id`, eventID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	defer speakerRows.Close()
+
+	speakers := []models.EventSpeaker{}
+	for speakerRows.Next() {
+		var speaker models.EventSpeaker
+		if err := speakerRows.Scan(&speaker.ID, &speaker.EventID, &speaker.Name, &speaker.Bio, &speaker.PhotoURL, &speaker.Links, &speaker.Position); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		speakers = append(speakers, speaker)
+	}
+
+	return c.JSON(fiber.Map{"hosts": hosts, "speakers": speakers})
+}
+
+// POST /api/admin/events/:id/hosts (ADMIN ONLY)
+// Body: { "user_id": 12, "role": "host" }
+func AddEventHost(c *fiber.Ctx) error {
+	/*
+		Designates a member as host or co-organizer of an event
+		Hosts can edit the event, manage its speakers and see the attendee list
+		Admin only
+	*/
+	eventID := c.Params("id")
+
+	var body struct {
+		UserID int    `json:"user_id"`
+		Role   string `json:"role"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+
+	if body.UserID == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "user_id is required"})
+	}
+	if body.Role == "" {
+		body.Role = models.HostRoleHost
+	}
+	if body.Role != models.HostRoleHost && body.Role != models.HostRoleCoOrganizer {
+		return c.Status(400).JSON(fiber.Map{"error": "Role must be host or co-organizer"})
+	}
+
+	_, err := db.Pool.Exec(context.Background(),
+		`INSERT INTO event_hosts (event_id, user_id, role) VALUES ($1, $2, $3)
+		 ON CONFLICT (event_id, user_id) DO UPDATE SET role = EXCLUDED.role`,
+		eventID, body.UserID, body.Role)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(400).JSON(fiber.Map{"error": "Event or user not found"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Host added successfully"})
+}
+
+// DELETE /api/admin/events/:id/hosts/:userId (ADMIN ONLY)
+func RemoveEventHost(c *fiber.Ctx) error {
+	/*
+		Removes a member from an event's hosts
+		Admin only
+	*/
+	result, err := db.Pool.Exec(context.Background(),
+		`DELETE FROM event_hosts WHERE event_id = $1 AND user_id = $2`,
+		c.Params("id"), c.Params("userId"))
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database delete failed"})
+	}
+
+	if result.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Host not found"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Host removed successfully"})
+}
+
+// POST /api/events/:id/speakers (HOSTS AND ADMINS)
+func AddEventSpeaker(c *fiber.Ctx) error {
+	/*
+		Adds an external speaker to an event
+		Requires the speaker's name, optional bio, photo_url, links and position
+	*/
+	eventID := c.Params("id")
+
+	var body models.EventSpeaker
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+
+	if strings.TrimSpace(body.Name) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Speaker name is required"})
+	}
+	if body.Links == nil {
+		body.Links = []string{}
+	}
+
+	var speakerID int
+	err := db.Pool.QueryRow(context.Background(),
+		`INSERT INTO event_speakers (event_id, name, bio, photo_url, links, position)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		eventID, body.Name, body.Bio, body.PhotoURL, body.Links, body.Position).Scan(&speakerID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Speaker added successfully", "id": speakerID})
+}
+
+// PUT /api/events/:id/speakers/:speakerId (HOSTS AND ADMINS)
+func UpdateEventSpeaker(c *fiber.Ctx) error {
+	/*
+		Updates an external speaker of an event
+	*/
+	var body models.EventSpeaker
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+
+	if strings.TrimSpace(body.Name) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Speaker name is required"})
+	}
+	if body.Links == nil {
+		body.Links = []string{}
+	}
+
+	result, err := db.Pool.Exec(context.Background(),
+		`UPDATE event_speakers SET name=$1, bio=$2, photo_url=$3, links=$4, position=$5
+		 WHERE id=$6 AND event_id=$7`,
+		body.Name, body.Bio, body.PhotoURL, body.Links, body.Position, c.Params("speakerId"), c.Params("id"))
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+
+	if result.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Speaker not found"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Speaker updated successfully"})
+}
+
+// DELETE /api/events/:id/speakers/:speakerId (HOSTS AND ADMINS)
+func DeleteEventSpeaker(c *fiber.Ctx) error {
+	/*
+		Removes an external speaker from an event
+	*/
+	result, err := db.Pool.Exec(context.Background(),
+		`DELETE FROM event_speakers WHERE id=$1 AND event_id=$2`,
+		c.Params("speakerId"), c.Params("id"))
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database delete failed"})
+	}
+
+	if result.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Speaker not found"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Speaker deleted successfully"})
+}
+
+// GET /api/events/:id/registrations (HOSTS AND ADMINS)
+func GetEventRegistrations(c *fiber.Ctx) error {
+	/*
+		Gets the full attendee list of an event for its organizers
+		Unlike the public attendees endpoint this includes emails and registration status
+	*/
+	rows, err := db.Pool.Query(context.Background(), `
+		SELECT u.id, u.name, u.email, u.picture, er.status, er.registered_at, er.checked_in_at
+		FROM users u
+		JOIN event_registrations er ON u.id = er.user_id
+		WHERE er.event_id = $1
+		ORDER BY u.name`, c.Params("id"))
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	defer rows.Close()
+
+	type Registrant struct {
+		ID           int        `json:"id"`
+		Name         string     `json:"name"`
+		Email        string     `json:"email"`
+		Picture      string     `json:"picture"`
+		Status       string     `json:"status"`
+		RegisteredAt time.Time  `json:"registered_at"`
+		CheckedInAt  *time.Time `json:"checked_in_at"`
+	}
+
+	registrants := []Registrant{}
+	for rows.Next() {
+		var registrant Registrant
+		if err := rows.Scan(&registrant.ID, &registrant.Name, &registrant.Email, &registrant.Picture, &registrant.Status, &registrant.RegisteredAt, &registrant.CheckedInAt); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		registrants = append(registrants, registrant)
+	}
+
+	return c.JSON(registrants)
+}
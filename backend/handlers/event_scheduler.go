@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// How often the scheduler looks for events that need a status change
+const eventSchedulerInterval = time.Minute
+
+func StartEventScheduler() {
+	/*
+		Starts a background loop that moves events through their lifecycle
+		Publishes drafts whose publish_at has passed
+		Marks published events as completed once they have ended
+	*/
+	go func() {
+		ticker := time.NewTicker(eventSchedulerInterval)
+		defer ticker.Stop()
+
+		for {
+			runEventSchedulerTick()
+			<-ticker.C
+		}
+	}()
+}
+
+func runEventSchedulerTick() {
+	rows, err := db.Pool.Query(context.Background(),
+		`UPDATE events SET status = $1
+		 WHERE status = $2 AND publish_at IS NOT NULL AND publish_at <= NOW()
+		 RETURNING id`,
+		models.EventPublished, models.EventDraft)
+	if err == nil {
+		var published []int
+		published, err = pgx.CollectRows(rows, pgx.RowTo[int])
+		if len(published) > 0 {
+			log.Printf("Event scheduler published %d event(s)", len(published))
+		}
+		for _, eventID := range published {
+			queueDiscordEventSync(eventID)
+		}
+	}
+	if err != nil {
+		log.Println("Event scheduler error (publish): ", err)
+	}
+
+	_, err = db.Pool.Exec(context.Background(),
+		`UPDATE events SET status = $1 WHERE status = $2 AND end_date < NOW()`,
+		models.EventCompleted, models.EventPublished)
+	if err != nil {
+		log.Println("Event scheduler error (complete): ", err)
+	}
+}
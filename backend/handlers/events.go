@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"log"
+	"time"
 
 	"github.com/KerlynD/CFA_Member_Profile/backend/db"
 	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/notifications"
 	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 )
 
 // GET /api/events
@@ -15,20 +19,25 @@ func GetEvents(c *fiber.Ctx) error {
 	/*
 		Gets all events from the database
 		Returns a JSON array of all events with registration status for the current user
+		Drafts are only included for admins
 	*/
 
 	// Try to get the current user (optional, as this endpoint might be public)
 	var currentUserID int
+	var isAdmin bool
 	token := utils.GetTokenFromRequest(c)
 	if token != "" {
 		claims, err := utils.VerifyJWT(token)
 		if err == nil {
 			currentUserID = claims.UserID
+			isAdmin = claims.IsAdmin
 		}
 	}
 
 	rows, err := db.Pool.Query(context.Background(),
-		"SELECT id, title, description, date, end_date, room, external_link, recording_url FROM events ORDER BY date DESC")
+		`SELECT id, title, description, date, end_date, room, external_link, recording_url, status, publish_at, cancelled_at, cancel_reason, capacity
+		 FROM events WHERE status <> $1 OR $2 ORDER BY date DESC`,
+		models.EventDraft, isAdmin)
 	if err != nil {
 		log.Println("Internal DB Error: ", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
@@ -38,7 +47,8 @@ func GetEvents(c *fiber.Ctx) error {
 	events := []models.Event{}
 	for rows.Next() {
 		var event models.Event
-		if err := rows.Scan(&event.ID, &event.Title, &event.Description, &event.Date, &event.EndDate, &event.Room, &event.ExternalLink, &event.RecordingURL); err != nil {
+		if err := rows.Scan(&event.ID, &event.Title, &event.Description, &event.Date, &event.EndDate, &event.Room, &event.ExternalLink, &event.RecordingURL,
+			&event.Status, &event.PublishAt, &event.CancelledAt, &event.CancelReason, &event.Capacity); err != nil {
 			log.Println("Scanner Error: ", err)
 			continue
 		}
@@ -46,20 +56,22 @@ func GetEvents(c *fiber.Ctx) error {
 		// Count attendees for this event
 		var attendees int
 		err := db.Pool.QueryRow(context.Background(),
-			"SELECT COUNT(*) FROM event_registrations WHERE event_id = $1", event.ID).Scan(&attendees)
+			"SELECT COUNT(*) FROM event_registrations WHERE event_id = $1 AND status <> $2",
+			event.ID, models.RegistrationWaitlisted).Scan(&attendees)
 		if err != nil {
 			attendees = 0
 		}
 		event.Attendees = attendees
 
-		// Check if current user is registered
+		// Check if current user is registered or waitlisted
 		if currentUserID > 0 {
-			var count int
+			var registrationStatus string
 			err := db.Pool.QueryRow(context.Background(),
-				"SELECT COUNT(*) FROM event_registrations WHERE event_id = $1 AND user_id = $2",
-				event.ID, currentUserID).Scan(&count)
-			if err == nil && count > 0 {
-				event.IsRegistered = true
+				"SELECT status FROM event_registrations WHERE event_id = $1 AND user_id = $2",
+				event.ID, currentUserID).Scan(&registrationStatus)
+			if err == nil {
+				event.IsRegistered = registrationStatus != models.RegistrationWaitlisted
+				event.IsWaitlisted = registrationStatus == models.RegistrationWaitlisted
 			}
 		}
 
@@ -73,6 +85,9 @@ func AddEvent(c *fiber.Ctx) error {
 	/*
 		Adds a new event to the database
 		Requires the event's title, description, date, end_date, room, external_link, and recording_url to be in the request body
+		Optional capacity (0 means unlimited) puts registrations past it on a waitlist
+		Optional status ("draft" or "published") and publish_at for scheduled publishing
+		Events with a publish_at in the future are always created as drafts
 	*/
 	var body models.Event
 	if err := c.BodyParser(&body); err != nil {
@@ -83,15 +98,34 @@ func AddEvent(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Title and description are required"})
 	}
 
-	_, err := db.Pool.Exec(context.Background(),
-		`INSERT INTO events (title, description, date, end_date, room, external_link, recording_url) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		body.Title, body.Description, body.Date, body.EndDate, body.Room, body.ExternalLink, body.RecordingURL)
+	if body.Capacity < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Capacity cannot be negative"})
+	}
+
+	status := body.Status
+	if status == "" {
+		status = models.EventPublished
+	}
+	if status != models.EventDraft && status != models.EventPublished {
+		return c.Status(400).JSON(fiber.Map{"error": "New events must be draft or published"})
+	}
+	if body.PublishAt != nil && body.PublishAt.After(time.Now()) {
+		status = models.EventDraft
+	}
+
+	var eventID int
+	err := db.Pool.QueryRow(context.Background(),
+		`INSERT INTO events (title, description, date, end_date, room, external_link, recording_url, status, publish_at, capacity) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		body.Title, body.Description, body.Date, body.EndDate, body.Room, body.ExternalLink, body.RecordingURL, status, body.PublishAt, body.Capacity).Scan(&eventID)
 	if err != nil {
 		log.Println("Internal DB Error: ", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
 	}
-	return c.JSON(fiber.Map{"message": "Event added successfully"})
+	if status == models.EventPublished {
+		queueDiscordEventSync(eventID)
+	}
+	return c.JSON(fiber.Map{"message": "Event added successfully", "id": eventID, "status": status})
 }
 
 // POST /api/events/:id/register
@@ -115,9 +149,29 @@ func RegisterForEvent(c *fiber.Ctx) error {
 
 	eventID := c.Params("id")
 
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(context.Background())
+
+	// Only published events accept registrations
+	// Lock the event row so concurrent registrations can't overfill it
+	var status string
+	var capacity int
+	err = tx.QueryRow(context.Background(),
+		"SELECT status, capacity FROM events WHERE id = $1 FOR UPDATE", eventID).Scan(&status, &capacity)
+	if err != nil || (status == models.EventDraft && !claims.IsAdmin) {
+		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
+	}
+	if status != models.EventPublished {
+		return c.Status(400).JSON(fiber.Map{"error": "This event is no longer accepting registrations"})
+	}
+
 	// Check if already registered
 	var count int
-	err = db.Pool.QueryRow(context.Background(),
+	err = tx.QueryRow(context.Background(),
 		"SELECT COUNT(*) FROM event_registrations WHERE event_id = $1 AND user_id = $2",
 		eventID, claims.UserID).Scan(&count)
 
@@ -130,17 +184,49 @@ func RegisterForEvent(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Already registered for this event"})
 	}
 
-	// Register the user
-	_, err = db.Pool.Exec(context.Background(),
-		"INSERT INTO event_registrations (event_id, user_id) VALUES ($1, $2)",
-		eventID, claims.UserID)
-
+	registrationStatus, err := addRegistration(tx, eventID, claims.UserID, capacity, models.RegistrationSourcePlatform)
 	if err != nil {
 		log.Println("Internal DB Error: ", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Registration failed"})
 	}
 
-	return c.JSON(fiber.Map{"message": "Successfully registered for event"})
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Registration failed"})
+	}
+
+	queueDiscordRoleSync(claims.UserID)
+
+	if registrationStatus == models.RegistrationWaitlisted {
+		go notifyRegistrant(claims.UserID, eventID, notifications.KindWaitlisted, "")
+		return c.JSON(fiber.Map{"message": "Event is full, you have been added to the waitlist", "status": registrationStatus})
+	}
+
+	go notifyRegistrant(claims.UserID, eventID, notifications.KindConfirmation, "")
+	return c.JSON(fiber.Map{"message": "Successfully registered for event", "status": registrationStatus})
+}
+
+// Helper function to register a member inside a transaction that holds the event row lock
+// Full events put new registrations on the waitlist (capacity 0 means unlimited)
+func addRegistration(tx pgx.Tx, eventID any, userID, capacity int, source string) (string, error) {
+	registrationStatus := models.RegistrationActive
+	if capacity > 0 {
+		var registered int
+		err := tx.QueryRow(context.Background(),
+			"SELECT COUNT(*) FROM event_registrations WHERE event_id = $1 AND status = $2",
+			eventID, models.RegistrationActive).Scan(&registered)
+		if err != nil {
+			return "", err
+		}
+		if registered >= capacity {
+			registrationStatus = models.RegistrationWaitlisted
+		}
+	}
+
+	_, err := tx.Exec(context.Background(),
+		"INSERT INTO event_registrations (event_id, user_id, status, source) VALUES ($1, $2, $3, $4)",
+		eventID, userID, registrationStatus, source)
+	return registrationStatus, err
 }
 
 // DELETE /api/events/:id/register
@@ -164,28 +250,100 @@ func UnregisterFromEvent(c *fiber.Ctx) error {
 
 	eventID := c.Params("id")
 
-	result, err := db.Pool.Exec(context.Background(),
-		"DELETE FROM event_registrations WHERE event_id = $1 AND user_id = $2",
-		eventID, claims.UserID)
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(context.Background())
+
+	// Lock the event row so only one unregistration promotes from the waitlist at a time
+	var eventStatus string
+	err = tx.QueryRow(context.Background(),
+		"SELECT status FROM events WHERE id = $1 FOR UPDATE", eventID).Scan(&eventStatus)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
+	}
 
+	promotedUserID, err := removeRegistration(tx, eventID, claims.UserID, eventStatus, models.RegistrationSourcePlatform)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.Status(400).JSON(fiber.Map{"error": "Not registered for this event"})
+	}
+	if errors.Is(err, errRegistrationClosed) {
+		return c.Status(409).JSON(fiber.Map{"error": "Registrations for cancelled or completed events can't be changed"})
+	}
 	if err != nil {
 		log.Println("Internal DB Error: ", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Unregistration failed"})
 	}
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return c.Status(400).JSON(fiber.Map{"error": "Not registered for this event"})
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Unregistration failed"})
+	}
+
+	queueDiscordRoleSync(claims.UserID)
+	if promotedUserID > 0 {
+		queueDiscordRoleSync(promotedUserID)
+		go notifyRegistrant(promotedUserID, eventID, notifications.KindWaitlistPromotion, "")
 	}
 
 	return c.JSON(fiber.Map{"message": "Successfully unregistered from event"})
 }
 
-// PUT /api/events/:id (ADMIN ONLY)
+// Returned by removeRegistration once an event is cancelled or completed, its registrations are kept as a record
+var errRegistrationClosed = errors.New("event is cancelled or completed")
+
+// Helper function to remove a registration inside a transaction that holds the event row lock
+// Records where the member withdrew (a registration source) and hands the freed spot to whoever has been waiting the longest
+// Returns pgx.ErrNoRows if the member wasn't registered
+func removeRegistration(tx pgx.Tx, eventID any, userID int, eventStatus, source string) (int, error) {
+	if eventStatus == models.EventCancelled || eventStatus == models.EventCompleted {
+		return 0, errRegistrationClosed
+	}
+
+	var registrationStatus string
+	err := tx.QueryRow(context.Background(),
+		"DELETE FROM event_registrations WHERE event_id = $1 AND user_id = $2 RETURNING status",
+		eventID, userID).Scan(&registrationStatus)
+	if err != nil {
+		return 0, err
+	}
+
+	// Remember where the member withdrew, the Discord RSVP sync doesn't sign up members who withdrew elsewhere
+	// A later Discord withdrawal never replaces one made on the platform
+	_, err = tx.Exec(context.Background(),
+		`INSERT INTO event_registration_withdrawals (event_id, user_id, source) VALUES ($1, $2, $3)
+		 ON CONFLICT (event_id, user_id) DO UPDATE SET source = EXCLUDED.source, withdrawn_at = NOW()
+		 WHERE EXCLUDED.source <> $4`,
+		eventID, userID, source, models.RegistrationSourceDiscord)
+	if err != nil {
+		return 0, err
+	}
+
+	var promotedUserID int
+	if registrationStatus == models.RegistrationActive && eventStatus == models.EventPublished {
+		err = tx.QueryRow(context.Background(),
+			`UPDATE event_registrations SET status = $1
+			 WHERE id = (
+				SELECT id FROM event_registrations
+				WHERE event_id = $2 AND status = $3
+				ORDER BY registered_at, id LIMIT 1
+			 )
+			 RETURNING user_id`,
+			models.RegistrationActive, eventID, models.RegistrationWaitlisted).Scan(&promotedUserID)
+		if err != nil && err != pgx.ErrNoRows {
+			return 0, err
+		}
+	}
+	return promotedUserID, nil
+}
+
+// PUT /api/events/:id (ADMINS AND EVENT HOSTS)
 func UpdateEvent(c *fiber.Ctx) error {
 	/*
 		Updates an event in the database
-		Admin only
+		Admins or hosts of the event only
 	*/
 	eventID := c.Params("id")
 
@@ -198,10 +356,14 @@ func UpdateEvent(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Title and description are required"})
 	}
 
+	if body.Capacity < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Capacity cannot be negative"})
+	}
+
 	result, err := db.Pool.Exec(context.Background(),
-		`UPDATE events SET title=$1, description=$2, date=$3, end_date=$4, room=$5, external_link=$6, recording_url=$7 
-		WHERE id=$8`,
-		body.Title, body.Description, body.Date, body.EndDate, body.Room, body.ExternalLink, body.RecordingURL, eventID)
+		`UPDATE events SET title=$1, description=$2, date=$3, end_date=$4, room=$5, external_link=$6, recording_url=$7, capacity=$8 
+		WHERE id=$9`,
+		body.Title, body.Description, body.Date, body.EndDate, body.Room, body.ExternalLink, body.RecordingURL, body.Capacity, eventID)
 
 	if err != nil {
 		log.Println("Internal DB Error: ", err)
@@ -213,19 +375,32 @@ func UpdateEvent(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
 	}
 
+	// Drafts are skipped, so this only edits events members can already see
+	queueDiscordEventSync(eventID)
 	return c.JSON(fiber.Map{"message": "Event updated successfully"})
 }
 
 // DELETE /api/events/:id (ADMIN ONLY)
 func DeleteEvent(c *fiber.Ctx) error {
 	/*
-		Deletes an event from the database
+		Deletes a draft event from the database
+		Events that were ever published must be cancelled instead so their registrations are kept
 		Admin only
 	*/
 	eventID := c.Params("id")
 
+	var status string
+	err := db.Pool.QueryRow(context.Background(),
+		"SELECT status FROM events WHERE id = $1", eventID).Scan(&status)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
+	}
+	if status != models.EventDraft {
+		return c.Status(409).JSON(fiber.Map{"error": "Only drafts can be deleted, cancel the event instead"})
+	}
+
 	result, err := db.Pool.Exec(context.Background(),
-		"DELETE FROM events WHERE id = $1", eventID)
+		"DELETE FROM events WHERE id = $1 AND status = $2", eventID, models.EventDraft)
 
 	if err != nil {
 		log.Println("Internal DB Error: ", err)
@@ -240,6 +415,123 @@ func DeleteEvent(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Event deleted successfully"})
 }
 
+// POST /api/admin/events/:id/publish (ADMIN ONLY)
+// Body (optional): { "publish_at": "2025-01-01T15:00:00Z" }
+func PublishEvent(c *fiber.Ctx) error {
+	/*
+		Publishes a draft event immediately, or schedules it if publish_at is in the future
+		Admin only
+	*/
+	eventID := c.Params("id")
+
+	var body struct {
+		PublishAt *time.Time `json:"publish_at"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+		}
+	}
+
+	var status string
+	err := db.Pool.QueryRow(context.Background(),
+		"SELECT status FROM events WHERE id = $1", eventID).Scan(&status)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
+	}
+	if status != models.EventDraft {
+		return c.Status(409).JSON(fiber.Map{"error": "Only drafts can be published"})
+	}
+
+	// Scheduled publishing is picked up by the event scheduler
+	if body.PublishAt != nil && body.PublishAt.After(time.Now()) {
+		_, err = db.Pool.Exec(context.Background(),
+			"UPDATE events SET publish_at = $1 WHERE id = $2", body.PublishAt, eventID)
+		if err != nil {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+		}
+		return c.JSON(fiber.Map{"message": "Event scheduled for publishing", "publish_at": body.PublishAt})
+	}
+
+	_, err = db.Pool.Exec(context.Background(),
+		"UPDATE events SET status = $1, publish_at = NOW() WHERE id = $2 AND status = $3",
+		models.EventPublished, eventID, models.EventDraft)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+
+	queueDiscordEventSync(eventID)
+	return c.JSON(fiber.Map{"message": "Event published successfully"})
+}
+
+// POST /api/admin/events/:id/cancel (ADMIN ONLY)
+// Body (optional): { "reason": "Speaker is sick" }
+func CancelEvent(c *fiber.Ctx) error {
+	/*
+		Cancels an event without deleting it
+		Keeps the event and its registrations, marking both as cancelled
+		Admin only
+	*/
+	eventID := c.Params("id")
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+		}
+	}
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(context.Background())
+
+	result, err := tx.Exec(context.Background(),
+		`UPDATE events SET status = $1, cancelled_at = NOW(), cancel_reason = $2
+		 WHERE id = $3 AND status IN ($4, $5)`,
+		models.EventCancelled, body.Reason, eventID, models.EventDraft, models.EventPublished)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+	if result.RowsAffected() == 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "Event not found or already cancelled/completed"})
+	}
+
+	rows, err := tx.Query(context.Background(),
+		"UPDATE event_registrations SET status = $1 WHERE event_id = $2 RETURNING user_id",
+		models.RegistrationCancelled, eventID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+	registrants, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+
+	go func() {
+		for _, userID := range registrants {
+			notifyRegistrant(userID, eventID, notifications.KindCancellation, body.Reason)
+		}
+	}()
+	queueDiscordEventSync(eventID)
+
+	return c.JSON(fiber.Map{"message": "Event cancelled successfully"})
+}
+
 // GET /api/events/:id/attendees
 func GetEventAttendees(c *fiber.Ctx) error {
 	/*
@@ -252,8 +544,9 @@ func GetEventAttendees(c *fiber.Ctx) error {
 		SELECT u.id, u.name, u.picture 
 		FROM users u
 		JOIN event_registrations er ON u.id = er.user_id
-		WHERE er.event_id = $1
-		ORDER BY u.name`, eventID)
+		JOIN events e ON e.id = er.event_id
+		WHERE er.event_id = $1 AND e.status <> $2 AND er.status <> $3
+		ORDER BY u.name`, eventID, models.EventDraft, models.RegistrationWaitlisted)
 
 	if err != nil {
 		log.Println("Internal DB Error: ", err)
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/currency"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GET /api/offers/currencies
+func GetExchangeRates(c *fiber.Ctx) error {
+	/*
+		Returns the currencies offers can be submitted in
+		Each rate is how many USD one unit of the currency is worth
+	*/
+	return c.JSON(currency.Rates())
+}
+
+// POST /api/admin/exchange-rates/refresh (ADMIN ONLY)
+func RefreshExchangeRates(c *fiber.Ctx) error {
+	/*
+		Re-reads the exchange-rate file after it was edited on the server
+		Keeps the current rates if the file is missing or invalid
+	*/
+	if err := currency.Reload(); err != nil {
+		log.Println("Exchange Rate Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reload exchange rates"})
+	}
+	return c.JSON(currency.Rates())
+}
+
+// PUT /api/admin/exchange-rates (ADMIN ONLY)
+// Body: { "rates": { "CAD": 0.73, "GBP": 1.27 } }
+func UpdateExchangeRates(c *fiber.Ctx) error {
+	/*
+		Replaces the exchange-rate table and saves it to the exchange-rate file
+		USD is always kept at 1
+	*/
+	var body currency.Table
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if len(body.Rates) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "At least one rate is required"})
+	}
+
+	if err := currency.Save(body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(currency.Rates())
+}
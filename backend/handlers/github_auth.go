@@ -1,168 +1,222 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/KerlynD/CFA_Member_Profile/backend/db"
 	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/secrets"
 	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
 )
 
-var (
-	githubClientID     string
-	githubClientSecret string
-	githubRedirectURL  string
-)
+// GitHub accounts are connected through the generic integration routes
+type githubOAuthProvider struct {
+	oauthCodeFlow
+	apiURL string
+}
+
+var githubProvider = &githubOAuthProvider{apiURL: "https://api.github.com"}
+
+func init() {
+	registerIntegration(githubProvider)
+}
 
 func InitGithubOAuth() {
 	/*
 		Loads environment variables from .env file
 		Initializes GitHub OAuth configuration
+		Only requests read access to the member's profile and email
 	*/
-	githubClientID = os.Getenv("GITHUB_CLIENT_ID")
-	githubClientSecret = os.Getenv("GITHUB_CLIENT_SECRET")
-	githubRedirectURL = os.Getenv("GITHUB_REDIRECT_URL")
-}
-
-// GET /api/auth/github/login
-func GithubLogin(c *fiber.Ctx) error {
-	// Verify user is authenticated
-	token := utils.GetTokenFromRequest(c)
-	claims, err := utils.VerifyJWT(token)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	githubProvider.config = &oauth2.Config{
+		RedirectURL:  os.Getenv("GITHUB_REDIRECT_URL"),
+		ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
+		ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+		Scopes:       []string{"read:user", "user:email"},
+		Endpoint:     github.Endpoint,
 	}
-
-	// Store user ID in state parameter to pass through OAuth flow
-	state := fmt.Sprintf("%d", claims.UserID)
-
-	// Only request read access to public repos and user email
-	redirectURL := fmt.Sprintf(
-		"https://github.com/login/oauth/authorize?client_id=%s&redirect_uri=%s&scope=read:user user:email&state=%s",
-		githubClientID,
-		githubRedirectURL,
-		state,
-	)
-
-	// Return the redirect URL as JSON for frontend to handle
-	return c.JSON(fiber.Map{"redirect_url": redirectURL})
 }
 
-// GET /api/auth/github/callback
-func GithubCallback(c *fiber.Ctx) error {
-	code := c.Query("code")
-	state := c.Query("state")
+func (p *githubOAuthProvider) Key() string  { return "github" }
+func (p *githubOAuthProvider) Name() string { return "GitHub" }
 
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:3000"
+func (p *githubOAuthProvider) FetchIdentity(ctx context.Context, token *oauth2.Token) (ProviderIdentity, error) {
+	var githubUser struct {
+		ID        int    `json:"id"`
+		Login     string `json:"login"`
+		AvatarURL string `json:"avatar_url"`
+		HTMLURL   string `json:"html_url"`
+	}
+	if err := fetchProviderJSON(ctx, p.apiURL+"/user", token, &githubUser); err != nil {
+		return ProviderIdentity{}, err
 	}
 
-	if code == "" {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=github_auth_failed")
+	return ProviderIdentity{
+		ID:         fmt.Sprintf("%d", githubUser.ID),
+		Username:   githubUser.Login,
+		AvatarURL:  githubUser.AvatarURL,
+		ProfileURL: githubUser.HTMLURL,
+	}, nil
+}
+
+// The access token is kept, encrypted, for syncing the member's activity and pinned repos
+func (p *githubOAuthProvider) Persist(ctx context.Context, userID int, identity ProviderIdentity, token *oauth2.Token) error {
+	// Tokens are only ever stored encrypted
+	encryptedToken, err := secrets.Encrypt(token.AccessToken)
+	if err != nil {
+		return fmt.Errorf("encrypting GitHub token: %w", err)
 	}
 
-	// Exchange code for access token
-	tokenURL := "https://github.com/login/oauth/access_token"
-	reqBody := fmt.Sprintf(
-		"client_id=%s&client_secret=%s&code=%s&redirect_uri=%s",
-		githubClientID,
-		githubClientSecret,
-		code,
-		githubRedirectURL,
+	_, err = db.Pool.Exec(ctx,
+		`INSERT INTO github_integrations (user_id, github_id, username, avatar_url, profile_url, access_token, top_repos, joined_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT (user_id) DO UPDATE SET
+		   github_id = EXCLUDED.github_id,
+		   username = EXCLUDED.username,
+		   avatar_url = EXCLUDED.avatar_url,
+		   profile_url = EXCLUDED.profile_url,
+		   access_token = EXCLUDED.access_token,
+		   joined_at = EXCLUDED.joined_at`,
+		userID,
+		identity.ID,
+		identity.Username,
+		identity.AvatarURL,
+		identity.ProfileURL,
+		encryptedToken,
+		[]string{}, // Empty array initially
+		time.Now(),
 	)
-
-	req, err := http.NewRequest("POST", tokenURL, nil)
 	if err != nil {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=github_auth_failed")
+		return err
 	}
 
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.URL.RawQuery = reqBody
+	// Fill in the activity summary right away instead of waiting for the scheduler
+	go func() {
+		if err := syncGithubStats(userID); err != nil {
+			log.Printf("GitHub sync failed for user %d: %v", userID, err)
+		}
+	}()
+	return nil
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+// Removes the integration and synced activity, then revokes the OAuth grant on GitHub
+// The integration is removed even if GitHub can't be reached
+func (p *githubOAuthProvider) Disconnect(ctx context.Context, userID int) error {
+	// Keep the token in memory only long enough to revoke it
+	accessToken, err := githubAccessToken(userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errIntegrationNotLinked
+	}
 	if err != nil {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=github_auth_failed")
+		log.Printf("Failed to load GitHub token for user %d: %v", userID, err)
 	}
-	defer resp.Body.Close()
 
-	var tokenResponse struct {
-		AccessToken string `json:"access_token"`
-		TokenType   string `json:"token_type"`
-		Scope       string `json:"scope"`
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
 	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=github_auth_failed")
+	defer tx.Rollback(ctx)
+
+	for _, query := range []string{
+		`DELETE FROM github_stats WHERE user_id = $1`,
+		`DELETE FROM github_integrations WHERE user_id = $1`,
+	} {
+		if _, err := tx.Exec(ctx, query, userID); err != nil {
+			return err
+		}
 	}
-
-	if tokenResponse.AccessToken == "" {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=github_auth_failed")
+	if err := tx.Commit(ctx); err != nil {
+		return err
 	}
 
-	// Fetch GitHub user info
-	userReq, err := http.NewRequest("GET", "https://api.github.com/user", nil)
-	if err != nil {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=github_auth_failed")
+	if accessToken != "" {
+		if err := p.revokeGrant(ctx, accessToken); err != nil {
+			log.Printf("Failed to revoke GitHub grant for user %d: %v", userID, err)
+		}
 	}
-	userReq.Header.Set("Authorization", "Bearer "+tokenResponse.AccessToken)
-	userReq.Header.Set("Accept", "application/json")
+	return nil
+}
 
-	userResp, err := client.Do(userReq)
+// Reports the member's GitHub link and whether the stored token still works
+func (p *githubOAuthProvider) Status(ctx context.Context, userID int) (models.IntegrationStatus, error) {
+	status := models.IntegrationStatus{TokenHealth: models.TokenNotStored}
+
+	var username, avatar, profileURL, storedToken, syncError string
+	var joinedAt *time.Time
+	err := db.Pool.QueryRow(ctx,
+		`SELECT gi.username, gi.avatar_url, gi.profile_url, gi.access_token, gi.joined_at, COALESCE(gs.sync_error, '')
+		 FROM github_integrations gi
+		 LEFT JOIN github_stats gs ON gs.user_id = gi.user_id
+		 WHERE gi.user_id = $1`,
+		userID,
+	).Scan(&username, &avatar, &profileURL, &storedToken, &joinedAt, &syncError)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status, nil
+	}
 	if err != nil {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=github_auth_failed")
+		return status, err
 	}
-	defer userResp.Body.Close()
 
-	var githubUser struct {
-		ID        int    `json:"id"`
-		Login     string `json:"login"`
-		AvatarURL string `json:"avatar_url"`
-		HTMLURL   string `json:"html_url"`
+	status.Linked = true
+	status.Handle = username
+	status.AvatarURL = avatar
+	status.ProfileURL = profileURL
+	status.LinkedAt = joinedAt
+	status.TokenHealth = storedTokenHealth(storedToken)
+
+	// A 401 on the last sync means the member revoked the app on GitHub
+	if status.TokenHealth == models.TokenOK && strings.HasSuffix(syncError, "returned 401") {
+		status.TokenHealth = models.TokenRejected
 	}
+	return status, nil
+}
 
-	if err := json.NewDecoder(userResp.Body).Decode(&githubUser); err != nil {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=github_auth_failed")
+// Helper function to revoke the OAuth grant behind an access token
+// Uses the applications API, which authenticates with the app's client ID and secret
+func (p *githubOAuthProvider) revokeGrant(ctx context.Context, accessToken string) error {
+	if p.config.ClientID == "" || p.config.ClientSecret == "" {
+		return fmt.Errorf("GITHUB_CLIENT_ID or GITHUB_CLIENT_SECRET not set")
 	}
 
-	// Save to database
-	userID := state // from state parameter
-	_, err = db.Pool.Exec(context.Background(),
-		`INSERT INTO github_integrations (user_id, github_id, username, avatar_url, profile_url, access_token, top_repos, joined_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		 ON CONFLICT (user_id) DO UPDATE SET
-		   github_id = EXCLUDED.github_id,
-		   username = EXCLUDED.username,
-		   avatar_url = EXCLUDED.avatar_url,
-		   profile_url = EXCLUDED.profile_url,
-		   access_token = EXCLUDED.access_token,
-		   joined_at = EXCLUDED.joined_at`,
-		userID,
-		fmt.Sprintf("%d", githubUser.ID),
-		githubUser.Login,
-		githubUser.AvatarURL,
-		githubUser.HTMLURL,
-		tokenResponse.AccessToken,
-		[]string{}, // Empty array initially
-		time.Now(),
-	)
+	payload, err := json.Marshal(map[string]string{"access_token": accessToken})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "DELETE",
+		fmt.Sprintf("%s/applications/%s/grant", p.apiURL, url.PathEscape(p.config.ClientID)),
+		bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.config.ClientID, p.config.ClientSecret)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
 
+	resp, err := providerHTTPClient.Do(req)
 	if err != nil {
-		fmt.Printf("Error saving GitHub integration: %v\n", err)
-		return c.Redirect(frontendURL + "/dashboard/profile?error=github_save_failed")
+		return err
 	}
+	defer resp.Body.Close()
 
-	// Redirect to profile with success
-	return c.Redirect(frontendURL + "/dashboard/profile?github_connected=true&tab=integrations")
+	// 404 means the grant was already revoked on GitHub's side
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("GitHub API returned %d", resp.StatusCode)
+	}
+	return nil
 }
 
 // GET /api/integrations/github
@@ -205,12 +259,7 @@ func GetGithubRepos(c *fiber.Ctx) error {
 	}
 
 	// Get access token from database
-	var accessToken string
-	err = db.Pool.QueryRow(context.Background(),
-		`SELECT access_token FROM github_integrations WHERE user_id = $1`,
-		claims.UserID,
-	).Scan(&accessToken)
-
+	accessToken, err := githubAccessToken(claims.UserID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "GitHub not connected"})
 	}
@@ -281,5 +330,23 @@ func SaveTopRepos(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save repos"})
 	}
 
+	// Warm the repo cache so the profile doesn't have to wait on GitHub
+	accessToken, err := githubAccessToken(claims.UserID)
+	if err == nil && accessToken != "" {
+		go refreshRepos(claims.UserID, body.Repos, accessToken)
+	}
+
 	return c.JSON(fiber.Map{"success": true, "repos": body.Repos})
 }
+
+// Helper function to load and decrypt a member's GitHub access token
+// The token must only be used for calls to GitHub, never logged or returned to the client
+func githubAccessToken(userID int) (string, error) {
+	var stored string
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT access_token FROM github_integrations WHERE user_id = $1`, userID).Scan(&stored)
+	if err != nil {
+		return "", err
+	}
+	return secrets.Decrypt(stored)
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/secrets"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultGithubRepoCacheHours = 6
+	// Most requests made to GitHub at once while refreshing repos
+	githubRepoFetchConcurrency = 4
+)
+
+var repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]+/[A-Za-z0-9._-]+$`)
+
+// Helper function to normalize an "owner/repo" name for the cache key
+func repoCacheKey(fullName string) string {
+	return strings.ToLower(strings.TrimSpace(fullName))
+}
+
+// Helper function to load a member's cached repos by name, repos that were never fetched are left out
+// Repos GitHub answered 404 for are kept as nil so they aren't fetched again on every view
+func loadCachedRepos(userID int, names []string) (map[string]*models.GithubRepo, error) {
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = repoCacheKey(name)
+	}
+
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT full_name, data, not_found FROM github_repo_cache WHERE user_id = $1 AND full_name = ANY($2)`, userID, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	repos := map[string]*models.GithubRepo{}
+	for rows.Next() {
+		var key string
+		var repo models.GithubRepo
+		var notFound bool
+		if err := rows.Scan(&key, &repo, &notFound); err != nil {
+			return nil, err
+		}
+		repos[key] = nil
+		if !notFound {
+			repos[key] = &repo
+		}
+	}
+	return repos, rows.Err()
+}
+
+// Helper function to refresh one of a member's repos in the cache
+// Entries are per member since what a token can see differs, a 404 only means this member lost access
+// Sends the stored ETag so unchanged repos cost a 304 instead of a full response
+func refreshRepo(userID int, fullName, accessToken string) error {
+	if !repoNamePattern.MatchString(fullName) {
+		return fmt.Errorf("invalid repo name %q", fullName)
+	}
+	key := repoCacheKey(fullName)
+
+	var etag string
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT etag FROM github_repo_cache WHERE user_id = $1 AND full_name = $2`, userID, key).Scan(&etag)
+	if err != nil && err != pgx.ErrNoRows {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", "https://api.github.com/repos/"+fullName, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := githubHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		_, err = db.Pool.Exec(context.Background(),
+			`UPDATE github_repo_cache SET fetched_at = NOW() WHERE user_id = $1 AND full_name = $2`, userID, key)
+		return err
+
+	case http.StatusOK:
+		var repo models.GithubRepo
+		if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+			return err
+		}
+		_, err = db.Pool.Exec(context.Background(),
+			`INSERT INTO github_repo_cache (user_id, full_name, data, etag, not_found, fetched_at) VALUES ($1, $2, $3, $4, FALSE, NOW())
+			 ON CONFLICT (user_id, full_name) DO UPDATE SET data = $3, etag = $4, not_found = FALSE, fetched_at = NOW()`,
+			userID, key, repo, resp.Header.Get("ETag"))
+		return err
+
+	case http.StatusNotFound:
+		// Deleted, renamed away or made private, remembered until the next scheduled refresh
+		_, err = db.Pool.Exec(context.Background(),
+			`INSERT INTO github_repo_cache (user_id, full_name, data, etag, not_found, fetched_at) VALUES ($1, $2, '{}', '', TRUE, NOW())
+			 ON CONFLICT (user_id, full_name) DO UPDATE SET data = '{}', etag = '', not_found = TRUE, fetched_at = NOW()`,
+			userID, key)
+		return err
+
+	default:
+		return fmt.Errorf("GitHub API returned %d for %s", resp.StatusCode, fullName)
+	}
+}
+
+// Helper function to refresh several of a member's repos at once, with at most githubRepoFetchConcurrency requests in flight
+func refreshRepos(userID int, names []string, accessToken string) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, githubRepoFetchConcurrency)
+
+	for _, name := range names {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := refreshRepo(userID, name, accessToken); err != nil {
+				log.Printf("GitHub repo refresh failed for %s: %v", name, err)
+			}
+		}(name)
+	}
+	wg.Wait()
+}
+
+// Helper function to get a member's pinned repos in their chosen order
+// Served from the cache, only repos that were never fetched are fetched now
+// Repos that no longer exist are left out, the scheduled refresh checks them again
+func pinnedRepos(userID int, names []string, accessToken string) []models.GithubRepo {
+	cached, err := loadCachedRepos(userID, names)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return []models.GithubRepo{}
+	}
+
+	missing := []string{}
+	for _, name := range names {
+		if _, ok := cached[repoCacheKey(name)]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 && accessToken != "" {
+		refreshRepos(userID, missing, accessToken)
+		if cached, err = loadCachedRepos(userID, names); err != nil {
+			log.Println("Internal DB Error: ", err)
+			return []models.GithubRepo{}
+		}
+	}
+
+	repos := []models.GithubRepo{}
+	for _, name := range names {
+		if repo := cached[repoCacheKey(name)]; repo != nil && !repo.Private {
+			repos = append(repos, *repo)
+		}
+	}
+	return repos
+}
+
+// Helper function to refresh pinned repos that are older than GITHUB_REPO_CACHE_HOURS (default 6)
+// Each member's repos are fetched with their own token
+func refreshStaleRepos() {
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT gi.user_id, gi.access_token, array_agg(r.name)
+		 FROM github_integrations gi
+		 CROSS JOIN LATERAL unnest(gi.top_repos) AS r(name)
+		 LEFT JOIN github_repo_cache c ON c.user_id = gi.user_id AND c.full_name = LOWER(r.name)
+		 WHERE gi.access_token <> ''
+		   AND (c.fetched_at IS NULL OR c.fetched_at < NOW() - make_interval(hours => $1))
+		 GROUP BY gi.user_id, gi.access_token`,
+		envInt("GITHUB_REPO_CACHE_HOURS", defaultGithubRepoCacheHours))
+	if err != nil {
+		log.Println("GitHub repo refresh error: ", err)
+		return
+	}
+
+	type pending struct {
+		userID int
+		token  string
+		names  []string
+	}
+	batches := []pending{}
+	for rows.Next() {
+		var batch pending
+		var stored string
+		if err := rows.Scan(&batch.userID, &stored, &batch.names); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		batch.token, err = secrets.Decrypt(stored)
+		if err != nil {
+			log.Println("Failed to decrypt GitHub token: ", err)
+			continue
+		}
+		batches = append(batches, batch)
+	}
+	rows.Close()
+
+	for _, batch := range batches {
+		refreshRepos(batch.userID, batch.names, batch.token)
+	}
+}
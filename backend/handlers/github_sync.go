@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// How often the scheduler looks for members whose GitHub stats are due
+	githubSyncSchedulerInterval = 15 * time.Minute
+	defaultGithubSyncHours      = 12
+	// Members synced per tick, keeps us well inside GitHub's rate limits
+	githubSyncBatchSize = 20
+	// Members can ask for a refresh at most this often
+	githubManualSyncCooldown = 10 * time.Minute
+	githubRecentPullRequests = 10
+)
+
+var githubHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// Everything we need in one GraphQL call
+const githubStatsQuery = `query {
+  viewer {
+    followers { totalCount }
+    following { totalCount }
+    repositories(first: 100, ownerAffiliations: OWNER, privacy: PUBLIC, isFork: false, orderBy: {field: PUSHED_AT, direction: DESC}) {
+      totalCount
+      nodes {
+        languages(first: 10, orderBy: {field: SIZE, direction: DESC}) {
+          edges { size node { name color } }
+        }
+      }
+    }
+    contributionsCollection {
+      contributionCalendar { totalContributions }
+      restrictedContributionsCount
+    }
+    pullRequests(first: 30, orderBy: {field: CREATED_AT, direction: DESC}) {
+      nodes {
+        title
+        url
+        state
+        createdAt
+        mergedAt
+        repository { nameWithOwner isPrivate }
+      }
+    }
+  }
+}`
+
+type githubStatsResponse struct {
+	Data struct {
+		Viewer struct {
+			Followers    struct{ TotalCount int } `json:"followers"`
+			Following    struct{ TotalCount int } `json:"following"`
+			Repositories struct {
+				TotalCount int `json:"totalCount"`
+				Nodes      []struct {
+					Languages struct {
+						Edges []struct {
+							Size int `json:"size"`
+							Node struct {
+								Name  string `json:"name"`
+								Color string `json:"color"`
+							} `json:"node"`
+						} `json:"edges"`
+					} `json:"languages"`
+				} `json:"nodes"`
+			} `json:"repositories"`
+			ContributionsCollection struct {
+				ContributionCalendar struct {
+					TotalContributions int `json:"totalContributions"`
+				} `json:"contributionCalendar"`
+				RestrictedContributionsCount int `json:"restrictedContributionsCount"`
+			} `json:"contributionsCollection"`
+			PullRequests struct {
+				Nodes []struct {
+					Title      string     `json:"title"`
+					URL        string     `json:"url"`
+					State      string     `json:"state"`
+					CreatedAt  time.Time  `json:"createdAt"`
+					MergedAt   *time.Time `json:"mergedAt"`
+					Repository struct {
+						NameWithOwner string `json:"nameWithOwner"`
+						IsPrivate     bool   `json:"isPrivate"`
+					} `json:"repository"`
+				} `json:"nodes"`
+			} `json:"pullRequests"`
+		} `json:"viewer"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Helper function to fetch a member's public GitHub activity with their access token
+func fetchGithubStats(accessToken string) (models.GithubStats, error) {
+	var stats models.GithubStats
+
+	payload, _ := json.Marshal(map[string]string{"query": githubStatsQuery})
+	req, err := http.NewRequest("POST", "https://api.github.com/graphql", bytes.NewReader(payload))
+	if err != nil {
+		return stats, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := githubHTTPClient.Do(req)
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return stats, fmt.Errorf("GitHub API returned %d", resp.StatusCode)
+	}
+
+	var result githubStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return stats, err
+	}
+	if len(result.Errors) > 0 {
+		return stats, errors.New(result.Errors[0].Message)
+	}
+	viewer := result.Data.Viewer
+
+	stats.Followers = viewer.Followers.TotalCount
+	stats.Following = viewer.Following.TotalCount
+	stats.PublicRepos = viewer.Repositories.TotalCount
+	// Private contributions are only counted, never shown
+	stats.ContributionsLastYear = viewer.ContributionsCollection.ContributionCalendar.TotalContributions -
+		viewer.ContributionsCollection.RestrictedContributionsCount
+
+	// Languages across public repos, by bytes of code
+	totals := map[string]*models.GithubLanguage{}
+	totalBytes := 0
+	for _, repo := range viewer.Repositories.Nodes {
+		for _, edge := range repo.Languages.Edges {
+			lang, ok := totals[edge.Node.Name]
+			if !ok {
+				lang = &models.GithubLanguage{Name: edge.Node.Name, Color: edge.Node.Color}
+				totals[edge.Node.Name] = lang
+			}
+			lang.Bytes += edge.Size
+			totalBytes += edge.Size
+		}
+	}
+	stats.Languages = []models.GithubLanguage{}
+	for _, lang := range totals {
+		lang.Percent = math.Round(float64(lang.Bytes)/float64(totalBytes)*1000) / 10
+		stats.Languages = append(stats.Languages, *lang)
+	}
+	sort.Slice(stats.Languages, func(i, j int) bool { return stats.Languages[i].Bytes > stats.Languages[j].Bytes })
+
+	// Recent pull requests to public repos only
+	stats.RecentPullRequests = []models.GithubPullRequest{}
+	for _, pr := range viewer.PullRequests.Nodes {
+		if pr.Repository.IsPrivate {
+			continue
+		}
+		stats.RecentPullRequests = append(stats.RecentPullRequests, models.GithubPullRequest{
+			Title:     pr.Title,
+			URL:       pr.URL,
+			Repo:      pr.Repository.NameWithOwner,
+			State:     pr.State,
+			CreatedAt: pr.CreatedAt,
+			MergedAt:  pr.MergedAt,
+		})
+		if len(stats.RecentPullRequests) == githubRecentPullRequests {
+			break
+		}
+	}
+
+	return stats, nil
+}
+
+// Helper function to sync one member's GitHub stats into the database
+// Failures are recorded so the member isn't retried until their next sync is due
+func syncGithubStats(userID int) error {
+	accessToken, err := githubAccessToken(userID)
+	if err != nil {
+		return err
+	}
+
+	stats, err := fetchGithubStats(accessToken)
+	if err != nil {
+		_, dbErr := db.Pool.Exec(context.Background(),
+			`INSERT INTO github_stats (user_id, synced_at, sync_error) VALUES ($1, NOW(), $2)
+			 ON CONFLICT (user_id) DO UPDATE SET synced_at = NOW(), sync_error = $2`,
+			userID, err.Error())
+		if dbErr != nil {
+			log.Println("Internal DB Error: ", dbErr)
+		}
+		return err
+	}
+
+	_, err = db.Pool.Exec(context.Background(),
+		`INSERT INTO github_stats (user_id, contributions_last_year, followers, following, public_repos, languages, recent_pull_requests, synced_at, sync_error)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), '')
+		 ON CONFLICT (user_id) DO UPDATE SET contributions_last_year = $2, followers = $3, following = $4, public_repos = $5,
+		 	languages = $6, recent_pull_requests = $7, synced_at = NOW(), sync_error = ''`,
+		userID, stats.ContributionsLastYear, stats.Followers, stats.Following, stats.PublicRepos,
+		stats.Languages, stats.RecentPullRequests)
+	return err
+}
+
+// Helper function to load a member's synced GitHub stats, nil if they were never synced
+func loadGithubStats(userID int) (*models.GithubStats, error) {
+	var stats models.GithubStats
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT contributions_last_year, followers, following, public_repos, languages, recent_pull_requests, synced_at, sync_error
+		 FROM github_stats WHERE user_id = $1`, userID).
+		Scan(&stats.ContributionsLastYear, &stats.Followers, &stats.Following, &stats.PublicRepos,
+			&stats.Languages, &stats.RecentPullRequests, &stats.SyncedAt, &stats.SyncError)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func StartGithubSyncScheduler() {
+	/*
+		Starts a background loop that refreshes connected members' GitHub stats and pinned repos
+		Each member is synced every GITHUB_SYNC_HOURS (default 12), a small batch at a time
+	*/
+	go func() {
+		ticker := time.NewTicker(githubSyncSchedulerInterval)
+		defer ticker.Stop()
+
+		for {
+			runGithubSyncTick()
+			<-ticker.C
+		}
+	}()
+}
+
+func runGithubSyncTick() {
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT gi.user_id
+		 FROM github_integrations gi
+		 LEFT JOIN github_stats gs ON gs.user_id = gi.user_id
+		 WHERE gi.access_token <> ''
+		   AND (gs.synced_at IS NULL OR gs.synced_at < NOW() - make_interval(hours => $1))
+		 ORDER BY gs.synced_at NULLS FIRST
+		 LIMIT $2`,
+		envInt("GITHUB_SYNC_HOURS", defaultGithubSyncHours), githubSyncBatchSize)
+	if err != nil {
+		log.Println("GitHub sync error: ", err)
+		return
+	}
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		log.Println("GitHub sync error: ", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err := syncGithubStats(userID); err != nil {
+			log.Printf("GitHub sync failed for user %d: %v", userID, err)
+		}
+	}
+	refreshStaleRepos()
+}
+
+// POST /api/integrations/github/sync
+func SyncMyGithubStats(c *fiber.Ctx) error {
+	/*
+		Refreshes the current user's GitHub stats right away, e.g. after connecting
+		Limited to once every few minutes
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	stats, err := loadGithubStats(claims.UserID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	if stats != nil && stats.SyncedAt != nil && time.Since(*stats.SyncedAt) < githubManualSyncCooldown {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "GitHub stats were synced recently, try again later"})
+	}
+
+	if err := syncGithubStats(claims.UserID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "GitHub not connected"})
+		}
+		log.Println("GitHub sync error: ", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to sync GitHub stats"})
+	}
+
+	stats, err = loadGithubStats(claims.UserID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	return c.JSON(stats)
+}
@@ -52,7 +52,13 @@ func GoogleCallback(c *fiber.Ctx) error {
 	}
 
 	// Get the user info from the Google API
-	response, err := http.Get("https://www.googleapis.com/oauth2/v2/userinfo?access_token=" + token.AccessToken)
+	// The token goes in a header, a URL would end up in error messages and proxy logs
+	request, err := http.NewRequest("GET", "https://www.googleapis.com/oauth2/v2/userinfo", nil)
+	if err != nil {
+		return c.Status(500).SendString("Failed to get user info")
+	}
+	request.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	response, err := http.DefaultClient.Do(request)
 	if err != nil {
 		return c.Status(500).SendString("Failed to get user info")
 	}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// Integration is anything shown in the integrations overview
+type Integration interface {
+	Key() string  // URL segment and overview key, e.g. "github"
+	Name() string // Display name, e.g. "GitHub"
+	Status(ctx context.Context, userID int) (models.IntegrationStatus, error)
+}
+
+// Provider is an integration members connect through OAuth
+// The generic routes drive the flow: AuthorizeURL -> Exchange -> FetchIdentity -> Persist
+type Provider interface {
+	Integration
+	AuthorizeURL(state string) string
+	Exchange(ctx context.Context, code string) (*oauth2.Token, error)
+	FetchIdentity(ctx context.Context, token *oauth2.Token) (ProviderIdentity, error)
+	Persist(ctx context.Context, userID int, identity ProviderIdentity, token *oauth2.Token) error
+	Disconnect(ctx context.Context, userID int) error
+}
+
+// Integration members can remove without going through OAuth, every Provider is one
+type disconnectable interface {
+	Integration
+	Disconnect(ctx context.Context, userID int) error
+}
+
+// Account details a provider returns for the member that just connected
+type ProviderIdentity struct {
+	ID         string
+	Username   string
+	FirstName  string
+	LastName   string
+	AvatarURL  string
+	ProfileURL string
+	Extra      map[string]string // Provider specific fields, e.g. Discord's discriminator
+}
+
+// Returned by Disconnect when the member never linked the provider
+var errIntegrationNotLinked = errors.New("integration not linked")
+
+// How long a member has to finish the provider's consent screen
+const oauthStateTTL = 15 * time.Minute
+
+// Audience of OAuth states, session tokens have none so a state can never pass as one
+const oauthStateAudience = "oauth_state"
+
+var integrations = map[string]Integration{}
+
+var providerHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// Helper function to add an integration to the registry, called from each provider's init
+func registerIntegration(integration Integration) {
+	if _, dup := integrations[integration.Key()]; dup {
+		panic("integration registered twice: " + integration.Key())
+	}
+	integrations[integration.Key()] = integration
+}
+
+// Helper function to look up a provider members can connect
+func findProvider(key string) (Provider, bool) {
+	provider, ok := integrations[key].(Provider)
+	return provider, ok
+}
+
+// Integration that is only reported in the overview, e.g. the Google sign-in account
+type statusIntegration struct {
+	key    string
+	name   string
+	status func(ctx context.Context, userID int) (models.IntegrationStatus, error)
+}
+
+func (s statusIntegration) Key() string  { return s.key }
+func (s statusIntegration) Name() string { return s.name }
+func (s statusIntegration) Status(ctx context.Context, userID int) (models.IntegrationStatus, error) {
+	return s.status(ctx, userID)
+}
+
+// Shared AuthorizeURL and Exchange for providers that follow the standard code flow
+// Tests can point the config's endpoint at a local fake OAuth server
+type oauthCodeFlow struct {
+	config *oauth2.Config
+}
+
+func (f *oauthCodeFlow) AuthorizeURL(state string) string {
+	return f.config.AuthCodeURL(state)
+}
+
+func (f *oauthCodeFlow) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	return f.config.Exchange(ctx, code)
+}
+
+// GET /api/integrations/:provider/connect
+func ConnectIntegration(c *fiber.Ctx) error {
+	return connectIntegration(c, c.Params("provider"))
+}
+
+// GET /api/integrations/:provider/callback
+func IntegrationCallback(c *fiber.Ctx) error {
+	return integrationCallback(c, c.Params("provider"))
+}
+
+// DELETE /api/integrations/:provider
+// POST /api/integrations/:provider/disconnect
+func DisconnectIntegration(c *fiber.Ctx) error {
+	return disconnectIntegration(c, c.Params("provider"))
+}
+
+// Handlers for the routes a provider was registered with before the generic ones existed
+// Their redirect URLs are configured on the provider's side, so they keep working
+func ConnectIntegrationFor(key string) fiber.Handler {
+	return func(c *fiber.Ctx) error { return connectIntegration(c, key) }
+}
+
+func IntegrationCallbackFor(key string) fiber.Handler {
+	return func(c *fiber.Ctx) error { return integrationCallback(c, key) }
+}
+
+func connectIntegration(c *fiber.Ctx, key string) error {
+	/*
+		Starts connecting a provider for the current user
+		The state parameter is signed so the callback can't be replayed for another member
+		Returns the provider's authorize URL as JSON for the frontend to redirect to
+	*/
+	token := utils.GetTokenFromRequest(c)
+	if token == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	provider, ok := findProvider(key)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown integration"})
+	}
+
+	state, err := signOAuthState(claims.UserID, provider.Key())
+	if err != nil {
+		log.Println("Failed to sign OAuth state: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start connection"})
+	}
+
+	return c.JSON(fiber.Map{"redirect_url": provider.AuthorizeURL(state)})
+}
+
+func integrationCallback(c *fiber.Ctx, key string) error {
+	/*
+		Handles the redirect back from a provider
+		Exchanges the authorization code, fetches the account and saves it for the member in the state
+		Always redirects to the frontend profile page, errors are passed as ?error=<provider>_<reason>
+	*/
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:3000"
+	}
+
+	provider, ok := findProvider(key)
+	if !ok {
+		return c.Redirect(frontendURL + "/dashboard/profile?error=integration_unknown")
+	}
+	fail := func(reason string) error {
+		return c.Redirect(frontendURL + "/dashboard/profile?tab=integrations&error=" + provider.Key() + "_" + reason)
+	}
+
+	// The member declined or the provider reported a problem
+	if c.Query("error") != "" {
+		return fail("auth_failed")
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		return fail("auth_failed")
+	}
+	userID, err := verifyOAuthState(c.Query("state"), provider.Key())
+	if err != nil {
+		return fail("auth_failed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	oauthToken, err := provider.Exchange(ctx, code)
+	if err != nil {
+		log.Printf("Failed to exchange %s authorization code: %v", provider.Key(), err)
+		return fail("token_exchange_failed")
+	}
+
+	identity, err := provider.FetchIdentity(ctx, oauthToken)
+	if err != nil {
+		log.Printf("Failed to fetch %s account: %v", provider.Key(), err)
+		return fail("userinfo_failed")
+	}
+
+	if err := provider.Persist(ctx, userID, identity, oauthToken); err != nil {
+		log.Printf("Failed to save %s integration: %v", provider.Key(), err)
+		return fail("save_failed")
+	}
+
+	return c.Redirect(frontendURL + "/dashboard/profile?tab=integrations&" + provider.Key() + "_connected=true")
+}
+
+func disconnectIntegration(c *fiber.Ctx, key string) error {
+	/*
+		Removes a provider's integration for the current user
+		Providers revoke their grant where the provider supports it
+		Succeeds when the provider was never connected
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	provider, ok := integrations[key].(disconnectable)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown integration"})
+	}
+
+	// Nothing to remove is fine, the frontend disconnects without checking first
+	err = provider.Disconnect(context.Background(), claims.UserID)
+	if errors.Is(err, errIntegrationNotLinked) {
+		return c.JSON(fiber.Map{"message": provider.Name() + " was not connected"})
+	}
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to disconnect " + provider.Name()})
+	}
+
+	return c.JSON(fiber.Map{"message": provider.Name() + " disconnected successfully"})
+}
+
+type oauthStateClaims struct {
+	UserID   int    `json:"user_id"`
+	Provider string `json:"provider"`
+	jwt.RegisteredClaims
+}
+
+// Helper function to derive the key OAuth states are signed with
+// States travel through the provider's redirect, so they never share a key with session tokens
+func oauthStateKey() []byte {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte(oauthStateAudience))
+	return mac.Sum(nil)
+}
+
+// Helper function to sign the OAuth state so callbacks only link accounts for the member who started
+func signOAuthState(userID int, provider string) (string, error) {
+	claims := &oauthStateClaims{
+		UserID:   userID,
+		Provider: provider,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oauthStateAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oauthStateTTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(oauthStateKey())
+}
+
+// Helper function to check a signed OAuth state and return the member it was issued for
+func verifyOAuthState(state, provider string) (int, error) {
+	claims := &oauthStateClaims{}
+	_, err := jwt.ParseWithClaims(state, claims, func(token *jwt.Token) (interface{}, error) {
+		return oauthStateKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(oauthStateAudience))
+	if err != nil {
+		return 0, err
+	}
+	if claims.Provider != provider || claims.UserID == 0 {
+		return 0, fmt.Errorf("OAuth state was issued for %q", claims.Provider)
+	}
+	return claims.UserID, nil
+}
+
+// Helper function to GET a provider API endpoint with the member's token and decode the JSON response
+func fetchProviderJSON(ctx context.Context, url string, token *oauth2.Token, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := providerHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", req.URL.Host, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/secrets"
+	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/oauth2"
+)
+
+const (
+	fakeOAuthCode  = "good-code"
+	fakeOAuthToken = "fake-access-token"
+)
+
+// A fake provider: OAuth authorize/token endpoints plus the user endpoints each provider reads
+type fakeOAuthServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	revoked   []string // GitHub grants revoked through DELETE /applications/:id/grant
+	exchanges int
+}
+
+func newFakeOAuthServer(t *testing.T) *fakeOAuthServer {
+	t.Helper()
+	f := &fakeOAuthServer{}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		f.mu.Lock()
+		f.exchanges++
+		f.mu.Unlock()
+		if r.Form.Get("code") != fakeOAuthCode {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"`+fakeOAuthToken+`","token_type":"bearer"}`)
+	})
+
+	authed := func(next func(w http.ResponseWriter)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+fakeOAuthToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			next(w)
+		}
+	}
+	mux.HandleFunc("GET /user", authed(func(w http.ResponseWriter) {
+		io.WriteString(w, `{"id":42,"login":"octo","avatar_url":"https://avatars.example/octo","html_url":"https://github.com/octo"}`)
+	}))
+	mux.HandleFunc("GET /users/@me", authed(func(w http.ResponseWriter) {
+		io.WriteString(w, `{"id":"1001","username":"wumpus","discriminator":"0","avatar":"abc"}`)
+	}))
+	mux.HandleFunc("GET /v2/userinfo", authed(func(w http.ResponseWriter) {
+		io.WriteString(w, `{"sub":"li-7","given_name":"Ada","family_name":"Lovelace","name":"Ada Lovelace","picture":"https://media.example/ada"}`)
+	}))
+	mux.HandleFunc("DELETE /applications/{id}/grant", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.revoked = append(f.revoked, r.PathValue("id"))
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// Helper function to point a provider's OAuth config and API at the fake server for one test
+func useFakeProvider(t *testing.T, f *fakeOAuthServer, key string) Provider {
+	t.Helper()
+	config := &oauth2.Config{
+		ClientID:     key + "-client",
+		ClientSecret: key + "-secret",
+		RedirectURL:  "http://localhost:8080/api/integrations/" + key + "/callback",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   f.URL + "/authorize",
+			TokenURL:  f.URL + "/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+
+	switch p := integrations[key].(type) {
+	case *githubOAuthProvider:
+		prevConfig, prevURL := p.config, p.apiURL
+		p.config, p.apiURL = config, f.URL
+		t.Cleanup(func() { p.config, p.apiURL = prevConfig, prevURL })
+	case *discordOAuthProvider:
+		prevConfig, prevURL := p.config, p.apiURL
+		p.config, p.apiURL = config, f.URL
+		t.Cleanup(func() { p.config, p.apiURL = prevConfig, prevURL })
+	case *linkedinOAuthProvider:
+		prevConfig, prevURL := p.config, p.apiURL
+		p.config, p.apiURL = config, f.URL
+		t.Cleanup(func() { p.config, p.apiURL = prevConfig, prevURL })
+	default:
+		t.Fatalf("%s is not an OAuth provider", key)
+	}
+
+	provider, _ := findProvider(key)
+	return provider
+}
+
+// Helper function to build an app with the generic integration routes
+func integrationTestApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/api/integrations/:provider/connect", ConnectIntegration)
+	app.Get("/api/integrations/:provider/callback", IntegrationCallback)
+	app.Delete("/api/integrations/:provider", DisconnectIntegration)
+	return app
+}
+
+// Helper function to start connecting a provider as a member and return the state from the authorize URL
+func connectAs(t *testing.T, app *fiber.App, f *fakeOAuthServer, key, session string) string {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/api/integrations/"+key+"/connect", nil)
+	req.Header.Set("Authorization", "Bearer "+session)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("connect returned %d", resp.StatusCode)
+	}
+
+	var body struct {
+		RedirectURL string `json:"redirect_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	authorize, err := url.Parse(body.RedirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(body.RedirectURL, f.URL+"/authorize") {
+		t.Fatalf("redirect_url %q doesn't point at the provider", body.RedirectURL)
+	}
+	if got := authorize.Query().Get("client_id"); got != key+"-client" {
+		t.Fatalf("client_id = %q", got)
+	}
+	state := authorize.Query().Get("state")
+	if state == "" {
+		t.Fatal("authorize URL has no state")
+	}
+	return state
+}
+
+// Helper function to hit the callback and return where it redirected to
+func callback(t *testing.T, app *fiber.App, key, code, state string) string {
+	t.Helper()
+	query := url.Values{"code": {code}, "state": {state}}
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/integrations/"+key+"/callback?"+query.Encode(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("callback returned %d", resp.StatusCode)
+	}
+	return resp.Header.Get("Location")
+}
+
+func TestOAuthStateIsNotASessionToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	state, err := signOAuthState(7, "github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID, err := verifyOAuthState(state, "github"); err != nil || userID != 7 {
+		t.Fatalf("verifyOAuthState = %d, %v", userID, err)
+	}
+	if _, err := verifyOAuthState(state, "discord"); err == nil {
+		t.Fatal("state issued for github was accepted for discord")
+	}
+	if _, err := utils.VerifyJWT(state); err == nil {
+		t.Fatal("OAuth state was accepted as a session token")
+	}
+
+	session, err := utils.GenerateJWT(7, "member@example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyOAuthState(session, "github"); err == nil {
+		t.Fatal("session token was accepted as an OAuth state")
+	}
+}
+
+func TestOAuthCodeFlowIdentity(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("FRONTEND_URL", "http://frontend.test")
+
+	tests := []struct {
+		key  string
+		want ProviderIdentity
+	}{
+		{"github", ProviderIdentity{ID: "42", Username: "octo", AvatarURL: "https://avatars.example/octo", ProfileURL: "https://github.com/octo"}},
+		{"discord", ProviderIdentity{ID: "1001", Username: "wumpus", AvatarURL: "https://cdn.discordapp.com/avatars/1001/abc.png"}},
+		{"linkedin", ProviderIdentity{ID: "li-7", FirstName: "Ada", LastName: "Lovelace", AvatarURL: "https://media.example/ada"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			f := newFakeOAuthServer(t)
+			provider := useFakeProvider(t, f, tt.key)
+			app := integrationTestApp()
+
+			session, err := utils.GenerateJWT(7, "member@example.com", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			state := connectAs(t, app, f, tt.key, session)
+
+			// Tampered or foreign states never reach the provider
+			if got := callback(t, app, tt.key, fakeOAuthCode, state+"x"); !strings.HasSuffix(got, "error="+tt.key+"_auth_failed") {
+				t.Fatalf("tampered state redirected to %q", got)
+			}
+			if got := callback(t, app, tt.key, fakeOAuthCode, session); !strings.HasSuffix(got, "error="+tt.key+"_auth_failed") {
+				t.Fatalf("session token as state redirected to %q", got)
+			}
+			// A code the provider rejects fails at the exchange
+			if got := callback(t, app, tt.key, "bad-code", state); !strings.HasSuffix(got, "error="+tt.key+"_token_exchange_failed") {
+				t.Fatalf("rejected code redirected to %q", got)
+			}
+			if f.exchanges != 1 {
+				t.Fatalf("provider saw %d token exchanges, want 1", f.exchanges)
+			}
+
+			token, err := provider.Exchange(context.Background(), fakeOAuthCode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			identity, err := provider.FetchIdentity(context.Background(), token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.ID != tt.want.ID || identity.Username != tt.want.Username || identity.FirstName != tt.want.FirstName ||
+				identity.LastName != tt.want.LastName || identity.AvatarURL != tt.want.AvatarURL || identity.ProfileURL != tt.want.ProfileURL {
+				t.Fatalf("identity = %+v, want %+v", identity, tt.want)
+			}
+		})
+	}
+}
+
+// Drives connect -> callback -> Persist -> Disconnect against a real database
+// Set TEST_DATABASE_URL to a database the migrations can run against
+func TestOAuthCodeFlowPersistAndDisconnect(t *testing.T) {
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("FRONTEND_URL", "http://frontend.test")
+
+	pool, err := pgxpool.New(context.Background(), dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevPool := db.Pool
+	db.Pool = pool
+	t.Cleanup(func() { pool.Close(); db.Pool = prevPool })
+	db.Migrate()
+
+	// GitHub tokens are encrypted before they're stored
+	tokenKey := make([]byte, 32)
+	if err := secrets.Load("test:"+base64.StdEncoding.EncodeToString(tokenKey), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"github", "discord", "linkedin"} {
+		t.Run(key, func(t *testing.T) {
+			f := newFakeOAuthServer(t)
+			provider := useFakeProvider(t, f, key)
+			app := integrationTestApp()
+
+			var userID int
+			email := fmt.Sprintf("oauth-%s@example.com", key)
+			err := pool.QueryRow(context.Background(),
+				`INSERT INTO users (google_id, name, email) VALUES ($1, $2, $3) RETURNING id`,
+				"test-"+key, "OAuth Test", email).Scan(&userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { pool.Exec(context.Background(), `DELETE FROM users WHERE id = $1`, userID) })
+
+			session, err := utils.GenerateJWT(userID, email, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			state := connectAs(t, app, f, key, session)
+			if got := callback(t, app, key, fakeOAuthCode, state); !strings.HasSuffix(got, key+"_connected=true") {
+				t.Fatalf("callback redirected to %q", got)
+			}
+
+			status, err := provider.Status(context.Background(), userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !status.Linked {
+				t.Fatalf("%s not linked after the callback", key)
+			}
+
+			// Disconnecting twice succeeds, the second time there is nothing to remove
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest("DELETE", "/api/integrations/"+key, nil)
+				req.Header.Set("Authorization", "Bearer "+session)
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("disconnect #%d returned %d", i+1, resp.StatusCode)
+				}
+			}
+
+			status, err = provider.Status(context.Background(), userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Linked {
+				t.Fatalf("%s still linked after disconnecting", key)
+			}
+			if key == "github" && (len(f.revoked) != 1 || f.revoked[0] != "github-client") {
+				t.Fatalf("revoked grants = %v", f.revoked)
+			}
+		})
+	}
+}
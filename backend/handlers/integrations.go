@@ -2,17 +2,29 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"log"
+	"time"
 
 	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/secrets"
 	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 )
 
+// Integrations that are only reported in the overview, the others register themselves in their own files
+func init() {
+	registerIntegration(statusIntegration{key: "google", name: "Google", status: googleIntegrationStatus})
+}
+
 // GET /api/integrations
 func GetIntegrationsOverview(c *fiber.Ctx) error {
 	/*
 		Gets the overview of all integrations for the current user
-		Returns a JSON object of the integrations overview
+		Every provider reports linked status, handle, linked-at time, verification state and token health
+		Returns a JSON object keyed by provider
 	*/
 
 	// Get & Verify JWT
@@ -30,62 +42,59 @@ func GetIntegrationsOverview(c *fiber.Ctx) error {
 		})
 	}
 
-	userID := claims.UserID
-
-	// Create container for integrations overview
-	integrationsOverview := fiber.Map{
-		"google":   fiber.Map{"linked": false},
-		"linkedin": fiber.Map{"linked": false},
-		"discord":  fiber.Map{"linked": false},
+	// One provider failing shouldn't hide the others
+	integrationsOverview := fiber.Map{}
+	for key, integration := range integrations {
+		status, err := integration.Status(context.Background(), claims.UserID)
+		if err != nil {
+			log.Printf("Failed to load %s integration status: %v", key, err)
+			status = models.IntegrationStatus{TokenHealth: models.TokenNotStored, Error: "Status unavailable"}
+		}
+		status.Provider = key
+		status.Name = integration.Name()
+		integrationsOverview[key] = status
 	}
 
-	// Check if Google is linked
-	var googleEmail string
-	err = db.Pool.QueryRow(context.Background(),
-		`SELECT email FROM users WHERE id = $1`,
-		userID,
-	).Scan(&googleEmail)
+	return c.JSON(integrationsOverview)
+}
 
-	if err == nil && googleEmail != "" {
-		integrationsOverview["google"] = fiber.Map{
-			"linked": true,
-			"email":  googleEmail,
-		}
-	}
+// Helper function to report the Google account the member signed up with
+func googleIntegrationStatus(ctx context.Context, userID int) (models.IntegrationStatus, error) {
+	status := models.IntegrationStatus{TokenHealth: models.TokenNotStored}
 
-	// Check if LinkedIn is linked
-	var linkedinCount int
-	err = db.Pool.QueryRow(context.Background(),
-		`SELECT COUNT(*) FROM work_history WHERE user_id = $1`,
+	var email, picture string
+	var createdAt *time.Time
+	err := db.Pool.QueryRow(ctx,
+		`SELECT COALESCE(email, ''), COALESCE(picture, ''), created_at FROM users WHERE id = $1`,
 		userID,
-	).Scan(&linkedinCount)
-
-	if err == nil && linkedinCount > 0 {
-		var name string
-		db.Pool.QueryRow(context.Background(),
-			`SELECT name FROM users WHERE id = $1`,
-			userID,
-		).Scan(&name)
-		integrationsOverview["linkedin"] = fiber.Map{
-			"linked": true,
-			"name":   name,
-		}
+	).Scan(&email, &picture, &createdAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status, nil
+	}
+	if err != nil {
+		return status, err
 	}
 
-	// Check if Discord is linked
-	var discordUsername, discordAvatar string
-	err = db.Pool.QueryRow(context.Background(),
-		`SELECT username, avatar_url FROM discord_integrations WHERE user_id = $1`,
-		userID,
-	).Scan(&discordUsername, &discordAvatar)
+	// Google only signs in members with a verified email
+	verified := true
+	status.Linked = email != ""
+	status.Handle = email
+	status.AvatarURL = picture
+	status.LinkedAt = createdAt
+	status.Verified = &verified
+	return status, nil
+}
 
-	if err == nil && discordUsername != "" {
-		integrationsOverview["discord"] = fiber.Map{
-			"linked":   true,
-			"username": discordUsername,
-			"avatar":   discordAvatar,
-		}
+// Helper function to classify a stored provider token without exposing it
+func storedTokenHealth(stored string) string {
+	if stored == "" {
+		return models.TokenNotStored
 	}
-
-	return c.JSON(integrationsOverview)
+	if _, err := secrets.Decrypt(stored); err != nil {
+		return models.TokenUnreadable
+	}
+	if secrets.NeedsRotation(stored) {
+		return models.TokenNeedsRotation
+	}
+	return models.TokenOK
 }
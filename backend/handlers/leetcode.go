@@ -3,20 +3,87 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/KerlynD/CFA_Member_Profile/backend/db"
 	"github.com/KerlynD/CFA_Member_Profile/backend/models"
 	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 )
 
 // structs moved to models/leetcode.go
 
+var leaderboardHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+var leetcodeUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,30}$`)
+
+// Returned by lookupLeaderboard when LEADERBOARD_SERVER_URL isn't set
+var errLeaderboardNotConfigured = errors.New("LEADERBOARD_SERVER_URL not set")
+
+// Members link LeetCode by username, there is no OAuth flow
+type leetcodeIntegration struct{}
+
+func init() {
+	registerIntegration(leetcodeIntegration{})
+}
+
+func (leetcodeIntegration) Key() string  { return "leetcode" }
+func (leetcodeIntegration) Name() string { return "LeetCode" }
+
+// Reports the linked LeetCode account from our own table
+// The external leaderboard isn't asked, the overview shouldn't wait on a third party
+func (leetcodeIntegration) Status(ctx context.Context, userID int) (models.IntegrationStatus, error) {
+	status := models.IntegrationStatus{TokenHealth: models.TokenNotStored}
+
+	stats, err := loadLeetCodeIntegration(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+
+	status.Linked = true
+	status.Handle = stats.Username
+	status.AvatarURL = stats.AvatarURL
+	status.ProfileURL = "https://leetcode.com/u/" + stats.Username
+	status.LinkedAt = &stats.LinkedAt
+	if stats.SyncError != "" {
+		status.Error = "Last sync failed"
+	}
+	return status, nil
+}
+
+// Snapshots belong to the account, so they go with it
+func (leetcodeIntegration) Disconnect(ctx context.Context, userID int) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `DELETE FROM leetcode_integrations WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errIntegrationNotLinked
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM leetcode_snapshots WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
 // GET /api/leetcode/lookup
 func GetLeetCodeStats(c *fiber.Ctx) error {
 	// Verify current user
@@ -29,54 +96,57 @@ func GetLeetCodeStats(c *fiber.Ctx) error {
 		})
 	}
 
-	// Resolve the user's Discord username from our integrations table
-	var discordUsername string
-	err = db.Pool.QueryRow(context.Background(),
-		`SELECT username FROM discord_integrations WHERE user_id=$1`, claims.UserID).Scan(&discordUsername)
-	if err != nil || discordUsername == "" {
-		return c.JSON(models.LeaderboardCard{
-			Available: false,
-			Message:   "Discord not linked. Connect your Discord in the Profile > Integrations tab.",
-		})
-	}
-
-	// Try to call external leaderboard API using discord username
-	serverURL := os.Getenv("LEADERBOARD_SERVER_URL")
-	if serverURL == "" {
-		serverURL = "https://server.rakibshahid.com"
-	}
-
 	card := models.LeaderboardCard{Available: false, Message: "Lookup currently down"}
 
-	// Call discord_lookup endpoint exactly like the public site does
-	fullURL := fmt.Sprintf("%s/api/discord_lookup", strings.TrimRight(serverURL, "/"))
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		fmt.Printf("Error creating request: %v\n", err)
+	// Members who linked LeetCode directly get their synced stats
+	stats, err := loadLeetCodeIntegration(context.Background(), claims.UserID)
+	if err == nil {
+		card.Available = true
+		card.Message = ""
+		card.LeetCodeUsername = stats.Username
+		card.Avatar = stats.AvatarURL
+		card.Problems = models.Problems{Count: stats.TotalSolved, Submission: stats.RecentSubmissions}
+		card.EasySolved = stats.EasySolved
+		card.MediumSolved = stats.MediumSolved
+		card.HardSolved = stats.HardSolved
+		card.ContestRating = stats.ContestRating
+		card.SyncedAt = stats.SyncedAt
+		if standing, ok := leaderboardStanding(claims.UserID); ok {
+			card.Points = float64(standing.Points)
+			card.Wins = standing.Wins
+			card.LocalRanking = standing.Rank
+		}
+		return c.JSON(card)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		log.Println("Internal DB Error: ", err)
 		return c.JSON(card)
 	}
 
-	req.Header.Set("discord-username", discordUsername)
+	notLinked := models.LeaderboardCard{
+		Available: false,
+		Message:   "LeetCode not linked. Add your LeetCode username in the Profile > Integrations tab.",
+	}
+	if os.Getenv("LEADERBOARD_SERVER_URL") == "" {
+		return c.JSON(notLinked)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return c.JSON(card)
+	// Resolve the user's Discord username from our integrations table
+	var discordUsername string
+	err = db.Pool.QueryRow(context.Background(),
+		`SELECT username FROM discord_integrations WHERE user_id=$1`, claims.UserID).Scan(&discordUsername)
+	if err != nil || discordUsername == "" {
+		return c.JSON(notLinked)
 	}
-	defer resp.Body.Close()
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	apiResp, status, err := lookupLeaderboard(discordUsername)
 	if err != nil {
+		log.Println("Error looking up leaderboard: ", err)
 		return c.JSON(card)
 	}
 
-	switch resp.StatusCode {
+	switch status {
 	case 200:
-		var apiResp models.LeaderboardAPIResponse
-		if err := json.Unmarshal(bodyBytes, &apiResp); err != nil {
-			fmt.Printf("Error decoding response: %v\n", err)
-			return c.JSON(card)
-		}
-
 		// Convert local_ranking (can be string or int)
 		localRanking := 0
 		switch v := apiResp.LocalRanking.(type) {
@@ -101,8 +171,241 @@ func GetLeetCodeStats(c *fiber.Ctx) error {
 	case 404:
 		card.Message = fmt.Sprintf("Discord user '%s' not found in leaderboard", discordUsername)
 	default:
-		card.Message = fmt.Sprintf("Lookup failed (status %d)", resp.StatusCode)
+		card.Message = fmt.Sprintf("Lookup failed (status %d)", status)
 	}
 
 	return c.JSON(card)
 }
+
+// GET /api/integrations/leetcode
+func GetLeetCodeIntegration(c *fiber.Ctx) error {
+	/*
+		Gets the current user's linked LeetCode account with its synced stats
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	stats, err := loadLeetCodeIntegration(context.Background(), claims.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "LeetCode not linked"})
+	}
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+
+	return c.JSON(stats)
+}
+
+// PUT /api/integrations/leetcode
+// Body: { "username": "leetcode_handle" }
+func LinkLeetCode(c *fiber.Ctx) error {
+	/*
+		Links a LeetCode account to the current user by username
+		The username is checked against LeetCode and the first sync happens right away
+		Changing to another account clears the old account's snapshots
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Username string `json:"username"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	username := strings.TrimSpace(body.Username)
+	if !leetcodeUsernamePattern.MatchString(username) {
+		return c.Status(400).JSON(fiber.Map{"error": "Please provide a valid LeetCode username"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var taken bool
+	err = db.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM leetcode_integrations WHERE LOWER(username) = LOWER($1) AND user_id <> $2)`,
+		username, claims.UserID).Scan(&taken)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	if taken {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "That LeetCode account is linked to another member"})
+	}
+
+	stats, err := fetchLeetCodeStats(ctx, username)
+	if errors.Is(err, errLeetCodeUserNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "LeetCode user not found"})
+	}
+	if err != nil {
+		log.Println("LeetCode lookup error: ", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Couldn't reach LeetCode, try again later"})
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(ctx)
+
+	// Snapshots of a different account would make the progress chart meaningless
+	_, err = tx.Exec(ctx,
+		`DELETE FROM leetcode_snapshots WHERE user_id = $1 AND EXISTS(
+		 	SELECT 1 FROM leetcode_integrations WHERE user_id = $1 AND LOWER(username) <> LOWER($2))`,
+		claims.UserID, stats.Username)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO leetcode_integrations (user_id, username) VALUES ($1, $2)
+		 ON CONFLICT (user_id) DO UPDATE SET username = $2,
+		 	linked_at = CASE WHEN LOWER(leetcode_integrations.username) = LOWER($2) THEN leetcode_integrations.linked_at ELSE NOW() END`,
+		claims.UserID, stats.Username)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+
+	if err := saveLeetCodeStats(ctx, claims.UserID, stats); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+
+	linked, err := loadLeetCodeIntegration(ctx, claims.UserID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	return c.JSON(linked)
+}
+
+// POST /api/integrations/leetcode/sync
+func SyncMyLeetCodeStats(c *fiber.Ctx) error {
+	/*
+		Refreshes the current user's LeetCode stats right away
+		Limited to once every few minutes
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	stats, err := loadLeetCodeIntegration(ctx, claims.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "LeetCode not linked"})
+	}
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	if stats.SyncedAt != nil && time.Since(*stats.SyncedAt) < leetcodeManualSyncCooldown {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "LeetCode stats were synced recently, try again later"})
+	}
+
+	if err := syncLeetCodeStats(ctx, claims.UserID); err != nil {
+		log.Println("LeetCode sync error: ", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to sync LeetCode stats"})
+	}
+
+	stats, err = loadLeetCodeIntegration(ctx, claims.UserID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	return c.JSON(stats)
+}
+
+// GET /api/integrations/leetcode/history?days=90
+func GetLeetCodeHistory(c *fiber.Ctx) error {
+	/*
+		Gets the current user's daily LeetCode snapshots for charting progress
+		Covers the last ?days days (default 90, at most 730), oldest first
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	days := c.QueryInt("days", 90)
+	if days < 1 || days > 730 {
+		return c.Status(400).JSON(fiber.Map{"error": "days must be between 1 and 730"})
+	}
+
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT to_char(snapshot_date, 'YYYY-MM-DD'), easy_solved, medium_solved, hard_solved, total_solved, contest_rating
+		 FROM leetcode_snapshots
+		 WHERE user_id = $1 AND snapshot_date > CURRENT_DATE - $2::int
+		 ORDER BY snapshot_date`, claims.UserID, days)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	defer rows.Close()
+
+	snapshots := []models.LeetCodeSnapshot{}
+	for rows.Next() {
+		var s models.LeetCodeSnapshot
+		if err := rows.Scan(&s.Date, &s.EasySolved, &s.MediumSolved, &s.HardSolved, &s.TotalSolved, &s.ContestRating); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	return c.JSON(snapshots)
+}
+
+// Helper function to look up a member on the external leaderboard by Discord username
+// Returns the lookup status code, the response is only set for 200
+func lookupLeaderboard(discordUsername string) (*models.LeaderboardAPIResponse, int, error) {
+	serverURL := os.Getenv("LEADERBOARD_SERVER_URL")
+	if serverURL == "" {
+		return nil, 0, errLeaderboardNotConfigured
+	}
+
+	// Call discord_lookup endpoint exactly like the public site does
+	fullURL := fmt.Sprintf("%s/api/discord_lookup", strings.TrimRight(serverURL, "/"))
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("discord-username", discordUsername)
+
+	resp, err := leaderboardHTTPClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, resp.StatusCode, nil
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	var apiResp models.LeaderboardAPIResponse
+	if err := json.Unmarshal(bodyBytes, &apiResp); err != nil {
+		return nil, 0, err
+	}
+	return &apiResp, resp.StatusCode, nil
+}
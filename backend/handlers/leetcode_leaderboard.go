@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// How often the cached leaderboards are recomputed from the synced stats
+	defaultLeaderboardRefreshMinutes = 30
+	defaultLeaderboardLimit          = 50
+	maxLeaderboardLimit              = 200
+
+	// Points per problem solved, harder problems are worth more
+	leetcodeEasyPoints   = 1
+	leetcodeMediumPoints = 3
+	leetcodeHardPoints   = 5
+)
+
+// Days each window looks back, all-time has no start
+var leaderboardWindowDays = map[string]int{
+	models.LeaderboardWeekly:  7,
+	models.LeaderboardMonthly: 30,
+	models.LeaderboardAllTime: 0,
+}
+
+// A member's standing in one window, with the schools they can be filtered by
+type leaderboardMember struct {
+	entry     models.LeaderboardEntry
+	schoolIDs []int
+}
+
+type cachedLeaderboard struct {
+	members    []leaderboardMember
+	computedAt time.Time
+}
+
+var leaderboardCache = struct {
+	sync.RWMutex
+	windows map[string]cachedLeaderboard
+}{windows: map[string]cachedLeaderboard{}}
+
+// Helper function to work out every linked member's progress in a window
+// Progress is measured against the last snapshot before the window, or the first one inside it
+// for members who linked LeetCode part way through, so nobody's whole history counts as one week
+func computeLeaderboard(ctx context.Context, window string) ([]leaderboardMember, error) {
+	var start *time.Time
+	if days := leaderboardWindowDays[window]; days > 0 {
+		t := time.Now().AddDate(0, 0, -days)
+		start = &t
+	}
+
+	rows, err := db.Pool.Query(ctx,
+		`SELECT li.user_id, u.name, COALESCE(u.picture, ''), li.username, COALESCE(di.username, ''), li.avatar_url,
+		        li.easy_solved, li.medium_solved, li.hard_solved,
+		        b.easy_solved, b.medium_solved, b.hard_solved,
+		        ARRAY(SELECT DISTINCT eh.school_id FROM education_history eh
+		              WHERE eh.user_id = li.user_id AND eh.school_id IS NOT NULL)
+		 FROM leetcode_integrations li
+		 JOIN users u ON u.id = li.user_id
+		 LEFT JOIN discord_integrations di ON di.user_id = li.user_id
+		 LEFT JOIN LATERAL (
+		 	SELECT s.easy_solved, s.medium_solved, s.hard_solved FROM leetcode_snapshots s
+		 	WHERE s.user_id = li.user_id AND $1::date IS NOT NULL
+		 	ORDER BY s.snapshot_date <= $1::date DESC,
+		 	         CASE WHEN s.snapshot_date <= $1::date THEN s.snapshot_date END DESC NULLS LAST,
+		 	         s.snapshot_date
+		 	LIMIT 1
+		 ) b ON true`, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []leaderboardMember{}
+	for rows.Next() {
+		var m leaderboardMember
+		var baseEasy, baseMedium, baseHard *int
+		e := &m.entry
+		if err := rows.Scan(&e.UserID, &e.Name, &e.Picture, &e.LeetCodeUsername, &e.DiscordUsername, &e.Avatar,
+			&e.EasySolved, &e.MediumSolved, &e.HardSolved, &baseEasy, &baseMedium, &baseHard, &m.schoolIDs); err != nil {
+			return nil, err
+		}
+		if start != nil {
+			// Without a snapshot there is nothing to compare against yet
+			if baseEasy == nil {
+				e.EasySolved, e.MediumSolved, e.HardSolved = 0, 0, 0
+			} else {
+				e.EasySolved = max(e.EasySolved-*baseEasy, 0)
+				e.MediumSolved = max(e.MediumSolved-*baseMedium, 0)
+				e.HardSolved = max(e.HardSolved-*baseHard, 0)
+			}
+		}
+		e.ProblemsSolved = e.EasySolved + e.MediumSolved + e.HardSolved
+		e.Points = e.EasySolved*leetcodeEasyPoints + e.MediumSolved*leetcodeMediumPoints + e.HardSolved*leetcodeHardPoints
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	wins, err := leaderboardWins(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	for i := range members {
+		members[i].entry.Wins = wins[members[i].entry.UserID]
+	}
+	return members, nil
+}
+
+// Helper function to count daily wins since start (all of them when nil)
+// A member wins a day by gaining the most points in the chapter that day, ties all count
+// Only days with a snapshot the day before count, a gap would lump several days into one
+func leaderboardWins(ctx context.Context, start *time.Time) (map[int]int, error) {
+	rows, err := db.Pool.Query(ctx,
+		`WITH daily AS (
+		 	SELECT user_id, snapshot_date, LAG(snapshot_date) OVER w AS previous_date,
+		 	       (easy_solved - LAG(easy_solved) OVER w) * $2 +
+		 	       (medium_solved - LAG(medium_solved) OVER w) * $3 +
+		 	       (hard_solved - LAG(hard_solved) OVER w) * $4 AS points
+		 	FROM leetcode_snapshots
+		 	WINDOW w AS (PARTITION BY user_id ORDER BY snapshot_date)
+		 ), ranked AS (
+		 	SELECT user_id, RANK() OVER (PARTITION BY snapshot_date ORDER BY points DESC) AS place
+		 	FROM daily
+		 	WHERE points > 0 AND snapshot_date = previous_date + 1
+		 	  AND ($1::date IS NULL OR snapshot_date > $1::date)
+		 )
+		 SELECT user_id, COUNT(*) FROM ranked WHERE place = 1 GROUP BY user_id`,
+		start, leetcodeEasyPoints, leetcodeMediumPoints, leetcodeHardPoints)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wins := map[int]int{}
+	for rows.Next() {
+		var userID, count int
+		if err := rows.Scan(&userID, &count); err != nil {
+			return nil, err
+		}
+		wins[userID] = count
+	}
+	return wins, rows.Err()
+}
+
+// Helper function to recompute every window and swap the results into the cache
+func refreshLeaderboards() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for window := range leaderboardWindowDays {
+		members, err := computeLeaderboard(ctx, window)
+		if err != nil {
+			log.Printf("LeetCode leaderboard refresh failed (%s): %v", window, err)
+			continue
+		}
+		leaderboardCache.Lock()
+		leaderboardCache.windows[window] = cachedLeaderboard{members: members, computedAt: time.Now()}
+		leaderboardCache.Unlock()
+	}
+}
+
+// Helper function to get a window from the cache, computing it if the first refresh hasn't finished
+func cachedLeaderboardWindow(window string) (cachedLeaderboard, error) {
+	leaderboardCache.RLock()
+	board, ok := leaderboardCache.windows[window]
+	leaderboardCache.RUnlock()
+	if ok {
+		return board, nil
+	}
+
+	members, err := computeLeaderboard(context.Background(), window)
+	if err != nil {
+		return board, err
+	}
+	board = cachedLeaderboard{members: members, computedAt: time.Now()}
+	leaderboardCache.Lock()
+	leaderboardCache.windows[window] = board
+	leaderboardCache.Unlock()
+	return board, nil
+}
+
+// Helper function to rank members by a metric, ties share a rank
+// Members with nothing to show in the window are left off
+func rankLeaderboard(members []leaderboardMember, sortBy string, schoolID int) []models.LeaderboardEntry {
+	metric := func(e models.LeaderboardEntry) int {
+		switch sortBy {
+		case models.LeaderboardSortWins:
+			return e.Wins
+		case models.LeaderboardSortProblems:
+			return e.ProblemsSolved
+		default:
+			return e.Points
+		}
+	}
+
+	entries := []models.LeaderboardEntry{}
+	for _, m := range members {
+		if m.entry.ProblemsSolved == 0 && m.entry.Wins == 0 {
+			continue
+		}
+		if schoolID > 0 && !containsInt(m.schoolIDs, schoolID) {
+			continue
+		}
+		entries = append(entries, m.entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if metric(a) != metric(b) {
+			return metric(a) > metric(b)
+		}
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.ProblemsSolved != b.ProblemsSolved {
+			return a.ProblemsSolved > b.ProblemsSolved
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	for i := range entries {
+		if i > 0 && metric(entries[i]) == metric(entries[i-1]) {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+	return entries
+}
+
+// Helper function to check whether a slice holds a value
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to find a member's all-time standing for their LeetCode card
+func leaderboardStanding(userID int) (models.LeaderboardEntry, bool) {
+	board, err := cachedLeaderboardWindow(models.LeaderboardAllTime)
+	if err != nil {
+		log.Println("LeetCode leaderboard error: ", err)
+		return models.LeaderboardEntry{}, false
+	}
+	for _, entry := range rankLeaderboard(board.members, models.LeaderboardSortPoints, 0) {
+		if entry.UserID == userID {
+			return entry, true
+		}
+	}
+	return models.LeaderboardEntry{}, false
+}
+
+func StartLeetCodeLeaderboardScheduler() {
+	/*
+		Starts a goroutine that recomputes the cached LeetCode leaderboards
+		Runs every LEETCODE_LEADERBOARD_MINUTES minutes (default 30), stats themselves only change when members sync
+	*/
+	interval := time.Duration(envInt("LEETCODE_LEADERBOARD_MINUTES", defaultLeaderboardRefreshMinutes)) * time.Minute
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			refreshLeaderboards()
+			<-ticker.C
+		}
+	}()
+}
+
+// GET /api/leetcode/leaderboard?window=weekly&sort=points&school_id=12&limit=50
+func GetLeetCodeLeaderboard(c *fiber.Ctx) error {
+	/*
+		Gets the chapter-wide LeetCode leaderboard for members who linked LeetCode
+		window is weekly (default), monthly or all_time, sort is points (default), wins or problems
+		school_id limits it to members who studied at that school
+		Returns the ranked entries plus the current user's own entry if they are on the board
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	window := c.Query("window", models.LeaderboardWeekly)
+	if _, ok := leaderboardWindowDays[window]; !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "window must be one of weekly, monthly, all_time"})
+	}
+	sortBy := c.Query("sort", models.LeaderboardSortPoints)
+	if sortBy != models.LeaderboardSortPoints && sortBy != models.LeaderboardSortWins && sortBy != models.LeaderboardSortProblems {
+		return c.Status(400).JSON(fiber.Map{"error": "sort must be one of points, wins, problems"})
+	}
+	schoolID := c.QueryInt("school_id", 0)
+	limit := c.QueryInt("limit", defaultLeaderboardLimit)
+	if limit < 1 || limit > maxLeaderboardLimit {
+		limit = defaultLeaderboardLimit
+	}
+
+	board, err := cachedLeaderboardWindow(window)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load leaderboard"})
+	}
+
+	entries := rankLeaderboard(board.members, sortBy, schoolID)
+	var me *models.LeaderboardEntry
+	for i := range entries {
+		if entries[i].UserID == claims.UserID {
+			me = &entries[i]
+			break
+		}
+	}
+	total := len(entries)
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return c.JSON(fiber.Map{
+		"window":      window,
+		"sort":        sortBy,
+		"computed_at": board.computedAt,
+		"total":       total,
+		"entries":     entries,
+		"me":          me,
+	})
+}
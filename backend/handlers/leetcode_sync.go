@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// How often the scheduler looks for members whose LeetCode stats are due
+	leetcodeSyncSchedulerInterval = 15 * time.Minute
+	defaultLeetCodeSyncHours      = 12
+	// Members synced per tick, LeetCode throttles bursts from one address
+	leetcodeSyncBatchSize  = 20
+	leetcodeRequestSpacing = 2 * time.Second
+	// Members can ask for a refresh at most this often
+	leetcodeManualSyncCooldown = 10 * time.Minute
+	leetcodeRecentSubmissions  = 10
+)
+
+const leetcodeGraphQLURL = "https://leetcode.com/graphql"
+
+var leetcodeHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// Returned when LeetCode has no account with the username
+var errLeetCodeUserNotFound = errors.New("LeetCode user not found")
+
+// Everything we need in one GraphQL call, all of it is public profile data
+const leetcodeStatsQuery = `query memberStats($username: String!, $limit: Int!) {
+  matchedUser(username: $username) {
+    username
+    profile { userAvatar ranking }
+    submitStatsGlobal { acSubmissionNum { difficulty count } }
+  }
+  userContestRanking(username: $username) { rating attendedContestsCount }
+  recentAcSubmissionList(username: $username, limit: $limit) { id title titleSlug timestamp }
+}`
+
+type leetcodeStatsResponse struct {
+	Data struct {
+		MatchedUser *struct {
+			Username string `json:"username"`
+			Profile  struct {
+				UserAvatar string `json:"userAvatar"`
+				Ranking    int    `json:"ranking"`
+			} `json:"profile"`
+			SubmitStatsGlobal struct {
+				AcSubmissionNum []struct {
+					Difficulty string `json:"difficulty"`
+					Count      int    `json:"count"`
+				} `json:"acSubmissionNum"`
+			} `json:"submitStatsGlobal"`
+		} `json:"matchedUser"`
+		UserContestRanking *struct {
+			Rating                float64 `json:"rating"`
+			AttendedContestsCount int     `json:"attendedContestsCount"`
+		} `json:"userContestRanking"`
+		RecentAcSubmissionList []struct {
+			ID        string `json:"id"`
+			Title     string `json:"title"`
+			TitleSlug string `json:"titleSlug"`
+			Timestamp string `json:"timestamp"`
+		} `json:"recentAcSubmissionList"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Helper function to fetch a member's public LeetCode stats by username
+func fetchLeetCodeStats(ctx context.Context, username string) (models.LeetCodeIntegration, error) {
+	var stats models.LeetCodeIntegration
+
+	payload, _ := json.Marshal(map[string]any{
+		"query":     leetcodeStatsQuery,
+		"variables": map[string]any{"username": username, "limit": leetcodeRecentSubmissions},
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", leetcodeGraphQLURL, bytes.NewReader(payload))
+	if err != nil {
+		return stats, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// LeetCode rejects GraphQL requests without a referer from its own site
+	req.Header.Set("Referer", "https://leetcode.com/u/"+username+"/")
+
+	resp, err := leetcodeHTTPClient.Do(req)
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return stats, fmt.Errorf("LeetCode API returned %d", resp.StatusCode)
+	}
+
+	var result leetcodeStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return stats, err
+	}
+	// Unknown users come back as a null matchedUser plus an error, other errors only affect optional fields
+	user := result.Data.MatchedUser
+	if user == nil {
+		if len(result.Errors) > 0 && result.Errors[0].Message != "That user does not exist." {
+			return stats, errors.New(result.Errors[0].Message)
+		}
+		return stats, errLeetCodeUserNotFound
+	}
+
+	stats.Username = user.Username
+	stats.AvatarURL = user.Profile.UserAvatar
+	stats.Ranking = user.Profile.Ranking
+	for _, solved := range user.SubmitStatsGlobal.AcSubmissionNum {
+		switch solved.Difficulty {
+		case "Easy":
+			stats.EasySolved = solved.Count
+		case "Medium":
+			stats.MediumSolved = solved.Count
+		case "Hard":
+			stats.HardSolved = solved.Count
+		case "All":
+			stats.TotalSolved = solved.Count
+		}
+	}
+	if ranking := result.Data.UserContestRanking; ranking != nil {
+		stats.ContestRating = ranking.Rating
+		stats.ContestsAttended = ranking.AttendedContestsCount
+	}
+
+	stats.RecentSubmissions = []models.Submission{}
+	for _, sub := range result.Data.RecentAcSubmissionList {
+		stats.RecentSubmissions = append(stats.RecentSubmissions, models.Submission{
+			ID:            sub.ID,
+			Title:         sub.Title,
+			TitleSlug:     sub.TitleSlug,
+			Timestamp:     sub.Timestamp,
+			StatusDisplay: "Accepted",
+		})
+	}
+
+	return stats, nil
+}
+
+// Helper function to save freshly fetched stats and today's snapshot
+func saveLeetCodeStats(ctx context.Context, userID int, stats models.LeetCodeIntegration) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		`UPDATE leetcode_integrations SET username = $1, avatar_url = $2, ranking = $3, easy_solved = $4, medium_solved = $5,
+		 	hard_solved = $6, total_solved = $7, contest_rating = $8, contests_attended = $9, recent_submissions = $10,
+		 	synced_at = NOW(), sync_error = ''
+		 WHERE user_id = $11`,
+		stats.Username, stats.AvatarURL, stats.Ranking, stats.EasySolved, stats.MediumSolved,
+		stats.HardSolved, stats.TotalSolved, stats.ContestRating, stats.ContestsAttended, stats.RecentSubmissions,
+		userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO leetcode_snapshots (user_id, easy_solved, medium_solved, hard_solved, total_solved, contest_rating)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (user_id, snapshot_date) DO UPDATE SET easy_solved = $2, medium_solved = $3, hard_solved = $4,
+		 	total_solved = $5, contest_rating = $6`,
+		userID, stats.EasySolved, stats.MediumSolved, stats.HardSolved, stats.TotalSolved, stats.ContestRating)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Helper function to sync one member's LeetCode stats into the database
+// Failures are recorded so the member isn't retried until their next sync is due
+func syncLeetCodeStats(ctx context.Context, userID int) error {
+	var username string
+	err := db.Pool.QueryRow(ctx,
+		`SELECT username FROM leetcode_integrations WHERE user_id = $1`, userID).Scan(&username)
+	if err != nil {
+		return err
+	}
+
+	stats, err := fetchLeetCodeStats(ctx, username)
+	if err != nil {
+		_, dbErr := db.Pool.Exec(ctx,
+			`UPDATE leetcode_integrations SET synced_at = NOW(), sync_error = $1 WHERE user_id = $2`,
+			err.Error(), userID)
+		if dbErr != nil {
+			log.Println("Internal DB Error: ", dbErr)
+		}
+		return err
+	}
+
+	return saveLeetCodeStats(ctx, userID, stats)
+}
+
+// Helper function to load a member's linked LeetCode account, pgx.ErrNoRows if they never linked one
+func loadLeetCodeIntegration(ctx context.Context, userID int) (models.LeetCodeIntegration, error) {
+	var stats models.LeetCodeIntegration
+	err := db.Pool.QueryRow(ctx,
+		`SELECT user_id, username, avatar_url, ranking, easy_solved, medium_solved, hard_solved, total_solved,
+		        contest_rating, contests_attended, recent_submissions, linked_at, synced_at, sync_error
+		 FROM leetcode_integrations WHERE user_id = $1`, userID).
+		Scan(&stats.UserID, &stats.Username, &stats.AvatarURL, &stats.Ranking, &stats.EasySolved, &stats.MediumSolved,
+			&stats.HardSolved, &stats.TotalSolved, &stats.ContestRating, &stats.ContestsAttended, &stats.RecentSubmissions,
+			&stats.LinkedAt, &stats.SyncedAt, &stats.SyncError)
+	return stats, err
+}
+
+func StartLeetCodeSyncScheduler() {
+	/*
+		Starts a background loop that refreshes linked members' LeetCode stats
+		Each member is synced every LEETCODE_SYNC_HOURS (default 12), a small batch at a time
+		Every sync also records the day's snapshot for progress charts
+	*/
+	go func() {
+		ticker := time.NewTicker(leetcodeSyncSchedulerInterval)
+		defer ticker.Stop()
+
+		for {
+			runLeetCodeSyncTick()
+			<-ticker.C
+		}
+	}()
+}
+
+func runLeetCodeSyncTick() {
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT user_id FROM leetcode_integrations
+		 WHERE synced_at IS NULL OR synced_at < NOW() - make_interval(hours => $1)
+		 ORDER BY synced_at NULLS FIRST
+		 LIMIT $2`,
+		envInt("LEETCODE_SYNC_HOURS", defaultLeetCodeSyncHours), leetcodeSyncBatchSize)
+	if err != nil {
+		log.Println("LeetCode sync error: ", err)
+		return
+	}
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		log.Println("LeetCode sync error: ", err)
+		return
+	}
+
+	for i, userID := range userIDs {
+		if i > 0 {
+			time.Sleep(leetcodeRequestSpacing)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := syncLeetCodeStats(ctx, userID); err != nil {
+			log.Printf("LeetCode sync failed for user %d: %v", userID, err)
+		}
+		cancel()
+	}
+}
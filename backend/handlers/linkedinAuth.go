@@ -2,30 +2,28 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
 	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/linkedin"
 )
 
-var linkedinOAuthConfig *oauth2.Config
-
 func InitLinkedinOAuth() {
 	/*
 		Loads environment variables from .env file
 		Creates a new OAuth2 configuration for LinkedIn integration
 		Sets the RedirectURL, ClientID, ClientSecret, and Endpoint for LinkedIn integration
 	*/
-	linkedinOAuthConfig = &oauth2.Config{
+	linkedinProvider.config = &oauth2.Config{
 		RedirectURL:  os.Getenv("LINKEDIN_REDIRECT_URL"),
 		ClientID:     os.Getenv("LINKEDIN_CLIENT_ID"),
 		ClientSecret: os.Getenv("LINKEDIN_CLIENT_SECRET"),
@@ -34,138 +32,6 @@ func InitLinkedinOAuth() {
 	}
 }
 
-func getCompanyLogoURL(companyName string) string {
-	/*
-		Returns a logo.dev logo URL for a given company name
-		Uses a lookup map for well-known companies
-	*/
-
-	logoDevToken := os.Getenv("LOGO_KEY")
-	if logoDevToken == "" {
-		log.Println("Warning: LOGO_KEY not set in environment variables")
-		logoDevToken = "YOUR_LOGO_KEY"
-	}
-
-	// Simple domain inference for well-known companies
-	lookup := map[string]string{
-		"Google":          "google.com",
-		"Datadog":         "datadoghq.com",
-		"Capital One":     "capitalone.com",
-		"Amazon":          "amazon.com",
-		"Microsoft":       "microsoft.com",
-		"Meta":            "meta.com",
-		"LinkedIn":        "linkedin.com",
-		"Apple":           "apple.com",
-		"Netflix":         "netflix.com",
-		"Tesla":           "tesla.com",
-		"Facebook":        "facebook.com",
-		"Twitter":         "twitter.com",
-		"Uber":            "uber.com",
-		"Airbnb":          "airbnb.com",
-		"Spotify":         "spotify.com",
-		"Salesforce":      "salesforce.com",
-		"Adobe":           "adobe.com",
-		"Oracle":          "oracle.com",
-		"IBM":             "ibm.com",
-		"Intel":           "intel.com",
-		"Cisco":           "cisco.com",
-		"Dell":            "dell.com",
-		"HP":              "hp.com",
-		"Slack":           "slack.com",
-		"Zoom":            "zoom.us",
-		"Dropbox":         "dropbox.com",
-		"GitHub":          "github.com",
-		"Reddit":          "reddit.com",
-		"Pinterest":       "pinterest.com",
-		"Snapchat":        "snap.com",
-		"TikTok":          "tiktok.com",
-		"Stripe":          "stripe.com",
-		"Square":          "squareup.com",
-		"PayPal":          "paypal.com",
-		"Venmo":           "venmo.com",
-		"Goldman Sachs":   "goldmansachs.com",
-		"Morgan Stanley":  "morganstanley.com",
-		"JPMorgan":        "jpmorgan.com",
-		"Bank of America": "bankofamerica.com",
-		"Wells Fargo":     "wellsfargo.com",
-		"Deloitte":        "deloitte.com",
-		"PwC":             "pwc.com",
-		"EY":              "ey.com",
-		"KPMG":            "kpmg.com",
-		"Accenture":       "accenture.com",
-		"McKinsey":        "mckinsey.com",
-	}
-
-	if domain, ok := lookup[companyName]; ok {
-		return fmt.Sprintf("https://img.logo.dev/%s?token=%s", domain, logoDevToken)
-	}
-
-	// Fallback: try lowercase name with .com
-	cleanName := strings.ReplaceAll(strings.ToLower(companyName), " ", "")
-	return fmt.Sprintf("https://img.logo.dev/%s.com?token=%s", cleanName, logoDevToken)
-}
-
-func getSchoolLogoURL(schoolName string) string {
-	/*
-		Returns a logo.dev logo URL for a given school name
-		Uses a lookup map for well-known schools
-		Fallback: tries lowercase name with .edu
-	*/
-
-	logoDevToken := os.Getenv("LOGO_KEY")
-	if logoDevToken == "" {
-		log.Println("Warning: LOGO_KEY not set in environment variables")
-		logoDevToken = "YOUR_LOGO_KEY"
-	}
-
-	// Simple domain inference for well-known schools
-	lookup := map[string]string{
-		"Queens College":                        "qc.cuny.edu",
-		"Columbia University":                   "columbia.edu",
-		"Harvard University":                    "harvard.edu",
-		"MIT":                                   "mit.edu",
-		"Massachusetts Institute of Technology": "mit.edu",
-		"Stanford University":                   "stanford.edu",
-		"Yale University":                       "yale.edu",
-		"Princeton University":                  "princeton.edu",
-		"University of Pennsylvania":            "upenn.edu",
-		"Cornell University":                    "cornell.edu",
-		"Brown University":                      "brown.edu",
-		"Dartmouth College":                     "dartmouth.edu",
-		"Duke University":                       "duke.edu",
-		"Northwestern University":               "northwestern.edu",
-		"University of Chicago":                 "uchicago.edu",
-		"Caltech":                               "caltech.edu",
-		"California Institute of Technology":    "caltech.edu",
-		"UC Berkeley":                           "berkeley.edu",
-		"University of California, Berkeley":    "berkeley.edu",
-		"UCLA":                                  "ucla.edu",
-		"University of California, Los Angeles": "ucla.edu",
-		"NYU":                                   "nyu.edu",
-		"New York University":                   "nyu.edu",
-		"University of Michigan":                "umich.edu",
-		"Georgia Tech":                          "gatech.edu",
-		"Carnegie Mellon University":            "cmu.edu",
-		"University of Texas at Austin":         "utexas.edu",
-		"University of Virginia":                "virginia.edu",
-		"Boston University":                     "bu.edu",
-		"Georgetown University":                 "georgetown.edu",
-		"Emory University":                      "emory.edu",
-		"Vanderbilt University":                 "vanderbilt.edu",
-		"Rice University":                       "rice.edu",
-		"University of Southern California":     "usc.edu",
-		"USC":                                   "usc.edu",
-	}
-
-	if domain, ok := lookup[schoolName]; ok {
-		return fmt.Sprintf("https://img.logo.dev/%s?token=%s", domain, logoDevToken)
-	}
-
-	// Fallback: try lowercase name with .edu
-	cleanName := strings.ReplaceAll(strings.ToLower(schoolName), " ", "")
-	return fmt.Sprintf("https://img.logo.dev/%s.edu?token=%s", cleanName, logoDevToken)
-}
-
 func safeString(data map[string]interface{}, keys ...string) string {
 	/*
 		Safely extracts nested string values from a map
@@ -195,105 +61,49 @@ func safeString(data map[string]interface{}, keys ...string) string {
 	return ""
 }
 
-func LinkedInIntegrationLogin(c *fiber.Ctx) error {
-	// Verify user is authenticated first
-	jwtToken := utils.GetTokenFromRequest(c)
-	if jwtToken == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	claims, err := utils.VerifyJWT(jwtToken)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	// Store user ID in state parameter to pass through OAuth flow
-	state := fmt.Sprintf("%d", claims.UserID)
-
-	// Generate LinkedIn OAuth URL with user ID in state
-	url := linkedinOAuthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
-
-	// Return the redirect URL as JSON for frontend to handle
-	return c.JSON(fiber.Map{"redirect_url": url})
+// LinkedIn accounts are connected through the generic integration routes
+type linkedinOAuthProvider struct {
+	oauthCodeFlow
+	apiURL string
 }
 
-func LinkedInIntegrationCallback(c *fiber.Ctx) error {
-	/*
-		Handles the callback from LinkedIn integration
-		Exchanges the authorization code for a token
-		Gets the user info from LinkedIn
-		Stores LinkedIn integration data for the current authenticated user
-		Redirects to the frontend profile page with success message
-	*/
-
-	code := c.Query("code")
-	state := c.Query("state") // This contains the user ID
-
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:3000"
-	}
-
-	// Check for error parameter first
-	if errorParam := c.Query("error"); errorParam != "" {
-		errorDesc := c.Query("error_description")
-		return c.Redirect(frontendURL + "/dashboard/profile?error=linkedin_auth_failed&details=" + errorDesc)
-	}
+var linkedinProvider = &linkedinOAuthProvider{apiURL: "https://api.linkedin.com"}
 
-	if code == "" {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=linkedin_auth_failed")
-	}
-
-	if state == "" {
-		return c.Redirect(frontendURL + "/dashboard/profile?error=linkedin_auth_failed")
-	}
+func init() {
+	registerIntegration(linkedinProvider)
+}
 
-	// Get user ID from state parameter (passed through OAuth flow)
-	userID := state
-
-	oauthToken, err := linkedinOAuthConfig.Exchange(context.Background(), code)
-	if err != nil {
-		log.Println("Failed to exchange authorization code for token: ", err)
-		return c.Redirect(frontendURL + "/dashboard/profile?error=linkedin_token_exchange_failed")
-	}
+func (p *linkedinOAuthProvider) Key() string  { return "linkedin" }
+func (p *linkedinOAuthProvider) Name() string { return "LinkedIn" }
 
+func (p *linkedinOAuthProvider) FetchIdentity(ctx context.Context, token *oauth2.Token) (ProviderIdentity, error) {
 	// Get the user profile from LinkedIn using OpenID Connect
-	request, _ := http.NewRequest("GET", "https://api.linkedin.com/v2/userinfo", nil)
-	request.Header.Set("Authorization", "Bearer "+oauthToken.AccessToken)
-	response, err := http.DefaultClient.Do(request)
-
-	if err != nil {
-		log.Println("Failed to get user info from LinkedIn: ", err)
-		return c.Redirect(frontendURL + "/dashboard/profile?error=linkedin_userinfo_failed")
-	}
-	defer response.Body.Close()
-
 	var profile map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&profile)
-
-	// Extract user data from OpenID Connect response
-	linkedinID, _ := profile["sub"].(string)
-	firstName, _ := profile["given_name"].(string)
-	lastName, _ := profile["family_name"].(string)
-
-	// Get profile picture
-	var avatarURL string
-	if picture, ok := profile["picture"].(string); ok {
-		avatarURL = picture
+	if err := fetchProviderJSON(ctx, p.apiURL+"/v2/userinfo", token, &profile); err != nil {
+		return ProviderIdentity{}, err
 	}
 
-	// Get headline - not available in OpenID Connect, will be empty
-	var headline string
-	if name, ok := profile["name"].(string); ok {
-		headline = name // Use full name as fallback
+	identity := ProviderIdentity{
+		ID:        safeString(profile, "sub"),
+		FirstName: safeString(profile, "given_name"),
+		LastName:  safeString(profile, "family_name"),
+		AvatarURL: safeString(profile, "picture"),
+		// Headline is not available in OpenID Connect, use the full name as fallback
+		Extra: map[string]string{"headline": safeString(profile, "name")},
+	}
+	if identity.ID == "" {
+		return identity, fmt.Errorf("LinkedIn userinfo has no subject")
 	}
+	return identity, nil
+}
 
+func (p *linkedinOAuthProvider) Persist(ctx context.Context, userID int, identity ProviderIdentity, token *oauth2.Token) error {
 	// Create LinkedIn profile URL - OpenID Connect doesn't provide vanity URL
 	// Store a placeholder that users can update later
 	profileURL := "https://www.linkedin.com/in/profile-not-set"
 
 	// Insert or update LinkedIn integration
-	_, err = db.Pool.Exec(context.Background(),
+	_, err := db.Pool.Exec(ctx,
 		`INSERT INTO linkedin_integrations (user_id, linkedin_id, profile_url, first_name, last_name, headline, avatar_url, connected_at)
 		 VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
 		 ON CONFLICT (user_id) DO UPDATE SET 
@@ -304,15 +114,48 @@ func LinkedInIntegrationCallback(c *fiber.Ctx) error {
 		 	headline = $6,
 		 	avatar_url = $7,
 		 	connected_at = NOW()`,
-		userID, linkedinID, profileURL, firstName, lastName, headline, avatarURL)
+		userID, identity.ID, profileURL, identity.FirstName, identity.LastName, identity.Extra["headline"], identity.AvatarURL)
+	return err
+}
 
+// LinkedIn tokens aren't stored, so there is no grant to revoke
+func (p *linkedinOAuthProvider) Disconnect(ctx context.Context, userID int) error {
+	result, err := db.Pool.Exec(ctx,
+		`DELETE FROM linkedin_integrations WHERE user_id = $1`, userID)
 	if err != nil {
-		log.Println("Database error: ", err)
-		return c.Redirect(frontendURL + "/dashboard/profile?error=linkedin_save_failed")
+		return err
 	}
+	if result.RowsAffected() == 0 {
+		return errIntegrationNotLinked
+	}
+	return nil
+}
+
+// Reports the member's LinkedIn link
+func (p *linkedinOAuthProvider) Status(ctx context.Context, userID int) (models.IntegrationStatus, error) {
+	status := models.IntegrationStatus{TokenHealth: models.TokenNotStored}
 
-	// Redirect to profile page with success message
-	return c.Redirect(frontendURL + "/dashboard/profile?tab=integrations&linkedin_connected=true")
+	var firstName, lastName, avatar, profileURL string
+	var connectedAt *time.Time
+	err := db.Pool.QueryRow(ctx,
+		`SELECT COALESCE(first_name, ''), COALESCE(last_name, ''), COALESCE(avatar_url, ''),
+		        COALESCE(profile_url, ''), connected_at
+		 FROM linkedin_integrations WHERE user_id = $1`,
+		userID,
+	).Scan(&firstName, &lastName, &avatar, &profileURL, &connectedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+
+	status.Linked = true
+	status.Handle = strings.TrimSpace(firstName + " " + lastName)
+	status.AvatarURL = avatar
+	status.ProfileURL = profileURL
+	status.LinkedAt = connectedAt
+	return status, nil
 }
 
 // GetLinkedInIntegration gets the current user's LinkedIn integration
@@ -364,42 +207,6 @@ func GetLinkedInIntegration(c *fiber.Ctx) error {
 	return c.JSON(integration)
 }
 
-// DisconnectLinkedIn removes the LinkedIn integration for the current user
-func DisconnectLinkedIn(c *fiber.Ctx) error {
-	// Get & Verify JWT
-	token := utils.GetTokenFromRequest(c)
-	if token == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Unauthorized/No JWT found",
-		})
-	}
-
-	claims, err := utils.VerifyJWT(token)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Expired/Invalid JWT",
-		})
-	}
-
-	userID := claims.UserID
-
-	// Delete LinkedIn integration
-	_, err = db.Pool.Exec(context.Background(),
-		`DELETE FROM linkedin_integrations WHERE user_id = $1`,
-		userID)
-
-	if err != nil {
-		log.Println("Database error: ", err)
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to disconnect LinkedIn",
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"message": "LinkedIn disconnected successfully",
-	})
-}
-
 // UpdateLinkedInProfileURL allows users to update their LinkedIn profile URL
 func UpdateLinkedInProfileURL(c *fiber.Ctx) error {
 	// Get & Verify JWT
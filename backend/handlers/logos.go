@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultLogoCacheTTLHours = 24 * 7
+	// Domains logo.dev has no logo for are retried sooner in case one gets added
+	logoMissingTTL = 24 * time.Hour
+	// How long browsers may reuse a logo before asking again
+	logoBrowserMaxAge = 24 * time.Hour
+	maxLogoBytes      = 512 * 1024
+	// Cloudinary folder logos are stored in, overridden by CLOUDINARY_LOGO_FOLDER
+	defaultLogoFolder = "CodeForAll-Logos"
+)
+
+var (
+	logoDomainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+	logoHTTPClient    = &http.Client{Timeout: 5 * time.Second}
+	// Collapses concurrent fetches of the same domain into one upstream request
+	logoFetches singleflight.Group
+)
+
+// A logo recorded in the logo_cache table, the image itself lives in Cloudinary
+type cachedLogo struct {
+	ImageURL  string
+	Missing   bool // logo.dev had no logo, serve a monogram
+	ExpiresAt time.Time
+}
+
+// Helper function to check a domain is something we could look up a logo for
+func validLogoDomain(domain string) bool {
+	return len(domain) <= 253 && logoDomainPattern.MatchString(domain)
+}
+
+// Helper function to build the URL browsers load a logo from, an explicitly set logo URL wins
+// Built per request from the host serving the API, so nothing derived from the deployment is stored
+// Points at our own logo cache so the logo.dev key never leaves the server
+func logoURL(c *fiber.Ctx, explicit, domain, name string) string {
+	if explicit != "" {
+		return explicit
+	}
+	u := c.BaseURL() + "/api/logos"
+	if domain = strings.ToLower(domain); validLogoDomain(domain) {
+		u += "/" + domain
+	}
+	return u + "?name=" + url.QueryEscape(name)
+}
+
+// Helper function to read the cache TTL from LOGO_CACHE_TTL_HOURS
+func logoCacheTTL() time.Duration {
+	return time.Duration(envInt("LOGO_CACHE_TTL_HOURS", defaultLogoCacheTTLHours)) * time.Hour
+}
+
+// Helper function to read a domain's logo from the cache, nil if it was never fetched
+func loadCachedLogo(domain string) (*cachedLogo, error) {
+	var logo cachedLogo
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT image_url, missing, expires_at FROM logo_cache WHERE domain = $1`, domain).
+		Scan(&logo.ImageURL, &logo.Missing, &logo.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &logo, nil
+}
+
+// Helper function to check a domain belongs to a registered company or school
+// Only those are fetched, so the endpoint can't be used to pull arbitrary domains through logo.dev
+func knownLogoDomain(domain string) (bool, error) {
+	var known bool
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT EXISTS (SELECT 1 FROM companies WHERE domain = $1) OR EXISTS (SELECT 1 FROM schools WHERE domain = $1)`,
+		domain).Scan(&known)
+	return known, err
+}
+
+// Helper function to download a domain's logo from logo.dev, upload it to Cloudinary and record it in the cache
+func fetchLogo(domain string) (*cachedLogo, error) {
+	token := os.Getenv("LOGO_KEY")
+	if token == "" {
+		return nil, errors.New("LOGO_KEY not set in environment variables")
+	}
+
+	// Errors from here on carry the request URL and with it the token, only their cause is passed on
+	req, err := http.NewRequest("GET", fmt.Sprintf("https://img.logo.dev/%s?token=%s&fallback=404", domain, token), nil)
+	if err != nil {
+		return nil, fmt.Errorf("building logo request for %s: %w", domain, withoutURL(err))
+	}
+	resp, err := logoHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching logo for %s: %w", domain, withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	logo := &cachedLogo{}
+	switch {
+	case resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "image/"):
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxLogoBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxLogoBytes {
+			return nil, errors.New("logo for " + domain + " is too large")
+		}
+		folder := os.Getenv("CLOUDINARY_LOGO_FOLDER")
+		if folder == "" {
+			folder = defaultLogoFolder
+		}
+		logo.ImageURL, err = uploadImageToCloudinary(data, domain, folder, "logo_"+strings.ReplaceAll(domain, ".", "_"))
+		if err != nil {
+			return nil, err
+		}
+		logo.ExpiresAt = time.Now().Add(logoCacheTTL())
+	case resp.StatusCode == http.StatusNotFound:
+		logo = &cachedLogo{Missing: true, ExpiresAt: time.Now().Add(logoMissingTTL)}
+	default:
+		return nil, fmt.Errorf("logo.dev returned %d for %s", resp.StatusCode, domain)
+	}
+
+	_, err = db.Pool.Exec(context.Background(),
+		`INSERT INTO logo_cache (domain, image_url, missing, fetched_at, expires_at)
+		 VALUES ($1, $2, $3, NOW(), $4)
+		 ON CONFLICT (domain) DO UPDATE SET image_url = $2, missing = $3, fetched_at = NOW(), expires_at = $4`,
+		domain, logo.ImageURL, logo.Missing, logo.ExpiresAt)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+	}
+	return logo, nil
+}
+
+// Helper function to strip the request URL from an HTTP client error
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// Helper function to draw a placeholder with the first letter of a name on a color picked from the domain
+func monogramSVG(domain, name string) []byte {
+	label := strings.TrimSpace(name)
+	if label == "" {
+		label = domain
+	}
+	initial := "?"
+	for _, r := range label {
+		if r != ' ' {
+			initial = strings.ToUpper(string(r))
+			break
+		}
+	}
+	switch initial {
+	case "<", ">", "&", "\"", "'":
+		initial = "?"
+	}
+
+	palette := []string{"#2563eb", "#7c3aed", "#db2777", "#dc2626", "#ea580c", "#16a34a", "#0d9488", "#4b5563"}
+	h := fnv.New32a()
+	h.Write([]byte(domain))
+	color := palette[h.Sum32()%uint32(len(palette))]
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="128" height="128" viewBox="0 0 128 128">`+
+		`<rect width="128" height="128" rx="24" fill="%s"/>`+
+		`<text x="64" y="64" dy=".35em" text-anchor="middle" font-family="Helvetica, Arial, sans-serif" font-size="64" font-weight="600" fill="#ffffff">%s</text>`+
+		`</svg>`, color, initial))
+}
+
+// Helper function to send the monogram placeholder
+func sendMonogram(c *fiber.Ctx, domain string, maxAge time.Duration) error {
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	c.Set(fiber.HeaderContentType, "image/svg+xml")
+	return c.Send(monogramSVG(domain, c.Query("name")))
+}
+
+// GET /api/logos/:domain?name=Capital%20One
+// GET /api/logos?name=Capital%20One
+func GetLogo(c *fiber.Ctx) error {
+	/*
+		Serves a company or school logo, fetching it from logo.dev into Cloudinary the first time
+		Only domains recorded on a company or school are fetched
+		Cached logos are refetched after LOGO_CACHE_TTL_HOURS (default 7 days)
+		Falls back to a generated monogram when there is no logo or the domain is missing, invalid or unknown,
+		using the optional name for the letter
+	*/
+	domain := strings.ToLower(strings.TrimSpace(c.Params("domain")))
+	if !validLogoDomain(domain) {
+		return sendMonogram(c, domain, logoBrowserMaxAge)
+	}
+
+	logo, err := loadCachedLogo(domain)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+	}
+
+	if logo == nil || time.Now().After(logo.ExpiresAt) {
+		known, err := knownLogoDomain(domain)
+		if err != nil {
+			log.Println("Internal DB Error: ", err)
+		}
+		if known {
+			fetched, err, _ := logoFetches.Do(domain, func() (any, error) { return fetchLogo(domain) })
+			if err != nil {
+				// Keep serving the stale copy if logo.dev or Cloudinary is unreachable
+				log.Println("Logo Fetch Error: ", err)
+			} else {
+				logo = fetched.(*cachedLogo)
+			}
+		} else if err == nil && logo == nil {
+			return sendMonogram(c, domain, logoBrowserMaxAge)
+		}
+	}
+
+	if logo == nil {
+		// Short max-age when the fetch failed so the real logo shows up once it works again
+		return sendMonogram(c, domain, 5*time.Minute)
+	}
+	if logo.Missing {
+		return sendMonogram(c, domain, logoBrowserMaxAge)
+	}
+
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("public, max-age=%d", int(logoBrowserMaxAge.Seconds())))
+	return c.Redirect(logo.ImageURL, fiber.StatusFound)
+}
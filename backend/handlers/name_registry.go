@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// Minimum similarity for a typo like "Captial One" to be suggested as an existing entry
+	fuzzyMatchThreshold = 0.8
+	// Shorter names are too easy to confuse ("Meta" and "Beta"), so they must match exactly
+	fuzzyMatchMinLength = 5
+	registrySearchLimit = 10
+	// Page size when browsing a registry without a search query
+	defaultRegistryPageSize = 50
+	maxRegistryPageSize     = 200
+)
+
+// A table of canonical names with alternate spellings, e.g. companies or schools
+// Aliases are stored as keys made of lowercase letters and digits only
+// Only exact alias matches resolve to an entry, close spellings are left for admins to merge
+type nameRegistry struct {
+	aliasTable string              // e.g. "company_aliases"
+	idColumn   string              // e.g. "company_id"
+	key        func(string) string // Extra normalization on top of nameKey, e.g. dropping "Inc."
+}
+
+// Helper function to reduce a name to lowercase letters and digits
+// Accents are folded away so "Nestlé" and "Nestle" share a key, letters outside Latin are kept as is
+func nameKey(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(strings.ToLower(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Helper function to compute how similar two keys are (0-1) from their edit distance
+func keySimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
+
+// Helper function to score a search query against an entry's aliases
+// Prefix matches always rank above fuzzy ones
+func searchScore(query string, aliases []string) float64 {
+	best := 0.0
+	for _, alias := range aliases {
+		score := keySimilarity(query, alias)
+		if strings.HasPrefix(alias, query) {
+			score = 1 + float64(len(query))/float64(len(alias))
+		}
+		best = max(best, score)
+	}
+	return best
+}
+
+// Helper function to find how similar two entries are from their closest pair of aliases
+func aliasSimilarity(a, b []string) float64 {
+	best := 0.0
+	for _, x := range a {
+		for _, y := range b {
+			if len(x) < fuzzyMatchMinLength || len(y) < fuzzyMatchMinLength {
+				continue
+			}
+			best = max(best, keySimilarity(x, y))
+		}
+	}
+	return best
+}
+
+// Helper function to list the alias keys a spelling is stored under
+func (r nameRegistry) keys(name string) []string {
+	keys := []string{}
+	for _, key := range []string{r.key(name), nameKey(name)} {
+		if key != "" && (len(keys) == 0 || keys[0] != key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Helper function to find the entry an alias key belongs to
+func (r nameRegistry) ownerOf(key string) (int, bool, error) {
+	var id int
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT `+r.idColumn+` FROM `+r.aliasTable+` WHERE alias = $1`, key).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	return id, err == nil, err
+}
+
+// Helper function to match a typed name to an entry by its exact alias keys
+func (r nameRegistry) match(name string) (int, bool, error) {
+	for _, key := range r.keys(name) {
+		id, ok, err := r.ownerOf(key)
+		if err != nil || ok {
+			return id, ok, err
+		}
+	}
+	return 0, false, nil
+}
+
+// Helper function to register the alias keys for a spelling of an entry
+func (r nameRegistry) addAliases(id int, name string) error {
+	for _, key := range r.keys(name) {
+		_, err := db.Pool.Exec(context.Background(),
+			`INSERT INTO `+r.aliasTable+` (alias, `+r.idColumn+`) VALUES ($1, $2) ON CONFLICT (alias) DO NOTHING`,
+			key, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to register the alias keys of every entry's canonical name
+func (r nameRegistry) aliasNames(table string) error {
+	rows, err := db.Pool.Query(context.Background(), `SELECT id, name FROM `+table)
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		id   int
+		name string
+	}
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (entry, error) {
+		var e entry
+		err := row.Scan(&e.id, &e.name)
+		return e, err
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := r.addAliases(e.id, e.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to list the distinct names in a column that aren't linked to the registry yet
+func unlinkedNames(query string) ([]string, error) {
+	rows, err := db.Pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func BackfillNameRegistries() {
+	/*
+		Links offers, work history and education history entered before the registries existed
+		Runs on startup after the migrations, matching goes through companyKey and schoolKey
+		so rows are linked exactly as they would be when saved today
+		Rows take the canonical spelling once, when they are first linked, later edits are left alone
+	*/
+	if err := companyRegistry.aliasNames("companies"); err != nil {
+		log.Println("Company alias backfill failed: ", err)
+		return
+	}
+	if err := schoolRegistry.aliasNames("schools"); err != nil {
+		log.Println("School alias backfill failed: ", err)
+		return
+	}
+
+	companies, err := unlinkedNames(
+		`SELECT company FROM offers WHERE company_id IS NULL
+		 UNION SELECT company FROM work_history WHERE company_id IS NULL`)
+	if err != nil {
+		log.Println("Company backfill failed: ", err)
+		return
+	}
+	linked := 0
+	for _, name := range companies {
+		if companyKey(name) == "" {
+			continue
+		}
+		company, err := resolveCompany(name)
+		if err != nil {
+			log.Printf("Company backfill failed for %q: %v", name, err)
+			continue
+		}
+		for _, table := range []string{"offers", "work_history"} {
+			tag, err := db.Pool.Exec(context.Background(),
+				`UPDATE `+table+` SET company_id = $1, company = $2 WHERE company_id IS NULL AND company = $3`,
+				company.ID, company.Name, name)
+			if err != nil {
+				log.Printf("Company backfill failed for %q: %v", name, err)
+				continue
+			}
+			linked += int(tag.RowsAffected())
+		}
+	}
+
+	schools, err := unlinkedNames(`SELECT DISTINCT school_name FROM education_history WHERE school_id IS NULL`)
+	if err != nil {
+		log.Println("School backfill failed: ", err)
+		return
+	}
+	for _, name := range schools {
+		if schoolKey(name) == "" {
+			continue
+		}
+		school, err := resolveSchool(name)
+		if err != nil {
+			log.Printf("School backfill failed for %q: %v", name, err)
+			continue
+		}
+		tag, err := db.Pool.Exec(context.Background(),
+			`UPDATE education_history SET school_id = $1, school_name = $2 WHERE school_id IS NULL AND school_name = $3`,
+			school.ID, school.Name, name)
+		if err != nil {
+			log.Printf("School backfill failed for %q: %v", name, err)
+			continue
+		}
+		linked += int(tag.RowsAffected())
+	}
+
+	if linked > 0 {
+		log.Printf("Linked %d rows to the company and school registries\n", linked)
+	}
+}
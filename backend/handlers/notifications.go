@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/notifications"
+	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// How often the scheduler looks for reminders to send
+const notificationSchedulerInterval = 5 * time.Minute
+
+// Column in notification_preferences that controls each notification kind
+var notificationPreferenceColumns = map[string]string{
+	notifications.KindConfirmation:      "confirmations",
+	notifications.KindWaitlisted:        "confirmations",
+	notifications.KindReminder24h:       "reminders",
+	notifications.KindReminder1h:        "reminders",
+	notifications.KindWaitlistPromotion: "waitlist",
+	notifications.KindCancellation:      "cancellations",
+	notifications.KindAnnouncement:      "announcements",
+}
+
+// Helper function to check whether a user wants a kind of notification
+func wantsNotification(userID int, kind string) bool {
+	column, ok := notificationPreferenceColumns[kind]
+	if !ok {
+		return false
+	}
+
+	wants := true
+	err := db.Pool.QueryRow(context.Background(),
+		fmt.Sprintf(`SELECT %s FROM notification_preferences WHERE user_id = $1`, column),
+		userID).Scan(&wants)
+	if err != nil {
+		// No row means the user never changed their defaults
+		return true
+	}
+	return wants
+}
+
+// Helper function to send a templated event notification to one registrant
+func notifyRegistrant(userID int, eventID string, kind string, reason string) {
+	/*
+		Renders and sends the notification for an event
+		Skips users who opted out and never sends the same kind twice for an event
+	*/
+	if !wantsNotification(userID, kind) {
+		return
+	}
+
+	var data notifications.EventData
+	var email string
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT u.name, u.email, e.title, e.date, e.room, e.external_link
+		 FROM users u, events e WHERE u.id = $1 AND e.id = $2`,
+		userID, eventID).Scan(&data.Name, &email, &data.EventTitle, &data.EventDate, &data.Room, &data.ExternalLink)
+	if err != nil || email == "" {
+		return
+	}
+	data.Reason = reason
+
+	// Claim the notification first so concurrent runs can't send duplicates
+	result, err := db.Pool.Exec(context.Background(),
+		`INSERT INTO notification_log (user_id, event_id, kind) VALUES ($1, $2, $3)
+		 ON CONFLICT (user_id, event_id, kind) DO NOTHING`,
+		userID, eventID, kind)
+	if err != nil {
+		log.Println("Notification log error: ", err)
+		return
+	}
+	if result.RowsAffected() == 0 {
+		return
+	}
+
+	subject, body, err := notifications.Render(kind, data)
+	if err != nil {
+		log.Println("Notification template error: ", err)
+		return
+	}
+
+	err = notifications.Send(context.Background(), notifications.Message{To: email, Subject: subject, Body: body})
+	if err != nil {
+		log.Printf("Failed to send %s notification to user %d: %v", kind, userID, err)
+		// Release the claim so the scheduler can retry reminders
+		db.Pool.Exec(context.Background(),
+			`DELETE FROM notification_log WHERE user_id = $1 AND event_id = $2 AND kind = $3`,
+			userID, eventID, kind)
+	}
+}
+
+func StartNotificationScheduler() {
+	/*
+		Starts a background loop that sends event reminders
+		Sends a reminder about 24 hours and 1 hour before each published event
+		Also moves waitlisted members up when an event's capacity was raised
+	*/
+	go func() {
+		ticker := time.NewTicker(notificationSchedulerInterval)
+		defer ticker.Stop()
+
+		for {
+			promoteWaitlists()
+			sendReminders(notifications.KindReminder24h, 23*time.Hour, 24*time.Hour)
+			sendReminders(notifications.KindReminder1h, 0, time.Hour)
+			<-ticker.C
+		}
+	}()
+}
+
+func sendReminders(kind string, from, to time.Duration) {
+	/*
+		Sends a reminder to every registrant of events starting between from and to from now
+		Registrants who already got this reminder are skipped
+		So are members who registered, or events that were published, after the window opened,
+		they just heard about the event and "starts tomorrow" would be wrong a few hours out
+	*/
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT er.user_id, er.event_id
+		 FROM event_registrations er
+		 JOIN events e ON e.id = er.event_id
+		 WHERE e.status = $1 AND er.status = $2
+		   AND e.date > NOW() + $3::interval AND e.date <= NOW() + $4::interval
+		   AND er.registered_at <= e.date - $4::interval
+		   AND (e.publish_at IS NULL OR e.publish_at <= e.date - $4::interval)
+		   AND NOT EXISTS (
+			SELECT 1 FROM notification_log nl
+			WHERE nl.user_id = er.user_id AND nl.event_id = er.event_id AND nl.kind = $5
+		   )`,
+		models.EventPublished, models.RegistrationActive,
+		fmt.Sprintf("%d seconds", int(from.Seconds())), fmt.Sprintf("%d seconds", int(to.Seconds())), kind)
+	if err != nil {
+		log.Println("Notification scheduler error: ", err)
+		return
+	}
+
+	type pending struct{ userID, eventID int }
+	reminders := []pending{}
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.userID, &p.eventID); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		reminders = append(reminders, p)
+	}
+	rows.Close()
+
+	for _, p := range reminders {
+		notifyRegistrant(p.userID, strconv.Itoa(p.eventID), kind, "")
+	}
+}
+
+func promoteWaitlists() {
+	/*
+		Promotes waitlisted registrants into any free spots
+		Spots open up when an admin raises an event's capacity or removes it
+	*/
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT DISTINCT e.id FROM events e
+		 JOIN event_registrations w ON w.event_id = e.id AND w.status = $1
+		 WHERE e.status = $2`,
+		models.RegistrationWaitlisted, models.EventPublished)
+	if err != nil {
+		log.Println("Waitlist promotion error: ", err)
+		return
+	}
+	eventIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		log.Println("Waitlist promotion error: ", err)
+		return
+	}
+
+	for _, eventID := range eventIDs {
+		promoted, err := promoteEventWaitlist(eventID)
+		if err != nil {
+			log.Printf("Waitlist promotion failed for event %d: %v", eventID, err)
+			continue
+		}
+		for _, userID := range promoted {
+			queueDiscordRoleSync(userID)
+			notifyRegistrant(userID, strconv.Itoa(eventID), notifications.KindWaitlistPromotion, "")
+		}
+	}
+}
+
+// Helper function to fill an event's free spots from its waitlist, oldest registrations first
+// Holds the event row lock like registrations do, so concurrent sign-ups can't push it over capacity
+func promoteEventWaitlist(eventID int) ([]int, error) {
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var status string
+	var capacity int
+	err = tx.QueryRow(context.Background(),
+		"SELECT status, capacity FROM events WHERE id = $1 FOR UPDATE", eventID).Scan(&status, &capacity)
+	if err != nil {
+		return nil, err
+	}
+	if status != models.EventPublished {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(context.Background(),
+		`UPDATE event_registrations SET status = $1
+		 WHERE id IN (
+			SELECT r.id FROM event_registrations r
+			WHERE r.event_id = $2 AND r.status = $3
+			ORDER BY r.registered_at, r.id
+			LIMIT CASE WHEN $4::int = 0 THEN NULL ELSE GREATEST($4::int - (
+				SELECT COUNT(*) FROM event_registrations a WHERE a.event_id = $2 AND a.status = $1
+			), 0) END
+		 )
+		 RETURNING user_id`,
+		models.RegistrationActive, eventID, models.RegistrationWaitlisted, capacity)
+	if err != nil {
+		return nil, err
+	}
+	promoted, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, err
+	}
+	return promoted, tx.Commit(context.Background())
+}
+
+// GET /api/notifications/preferences
+func GetNotificationPreferences(c *fiber.Ctx) error {
+	/*
+		Gets the current user's email notification preferences
+		Users without saved preferences get everything enabled
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized/No JWT found"})
+	}
+
+	prefs := models.NotificationPreferences{
+		UserID:        claims.UserID,
+		Confirmations: true,
+		Reminders:     true,
+		Waitlist:      true,
+		Cancellations: true,
+		Announcements: true,
+	}
+	db.Pool.QueryRow(context.Background(),
+		`SELECT confirmations, reminders, waitlist, cancellations, announcements, updated_at
+		 FROM notification_preferences WHERE user_id = $1`, claims.UserID).
+		Scan(&prefs.Confirmations, &prefs.Reminders, &prefs.Waitlist, &prefs.Cancellations, &prefs.Announcements, &prefs.UpdatedAt)
+
+	return c.JSON(prefs)
+}
+
+// PUT /api/notifications/preferences
+func UpdateNotificationPreferences(c *fiber.Ctx) error {
+	/*
+		Saves the current user's email notification preferences
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized/No JWT found"})
+	}
+
+	// Pointers tell omitted fields apart from false, omitted ones keep their saved value
+	var body struct {
+		Confirmations *bool `json:"confirmations"`
+		Reminders     *bool `json:"reminders"`
+		Waitlist      *bool `json:"waitlist"`
+		Cancellations *bool `json:"cancellations"`
+		Announcements *bool `json:"announcements"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+
+	// New rows start from the defaults (everything enabled)
+	_, err = db.Pool.Exec(context.Background(),
+		`INSERT INTO notification_preferences (user_id, confirmations, reminders, waitlist, cancellations, announcements, updated_at)
+		 VALUES ($1, COALESCE($2, true), COALESCE($3, true), COALESCE($4, true), COALESCE($5, true), COALESCE($6, true), NOW())
+		 ON CONFLICT (user_id) DO UPDATE SET
+		   confirmations = COALESCE($2, notification_preferences.confirmations),
+		   reminders = COALESCE($3, notification_preferences.reminders),
+		   waitlist = COALESCE($4, notification_preferences.waitlist),
+		   cancellations = COALESCE($5, notification_preferences.cancellations),
+		   announcements = COALESCE($6, notification_preferences.announcements),
+		   updated_at = NOW()`,
+		claims.UserID, body.Confirmations, body.Reminders, body.Waitlist, body.Cancellations, body.Announcements)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save preferences"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Notification preferences updated"})
+}
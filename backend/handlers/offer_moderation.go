@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/currency"
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Moderation defaults, each can be overridden through the environment
+const (
+	defaultOfferNewAccountDays  = 7  // OFFER_NEW_ACCOUNT_DAYS
+	defaultOfferReportThreshold = 3  // OFFER_REPORT_THRESHOLD
+	offerDuplicateWindowDays    = 30 // Same company and role resubmitted within this window
+	offerOutlierMinSamples      = 3  // Need this many approved offers before judging outliers
+	offerOutlierFactor          = 2.0
+)
+
+// Helper function to read a positive integer setting from the environment
+func envInt(name string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// Helper function to decide whether a submission needs review before going public
+// Returns the reasons the offer was flagged, empty when it can be approved right away
+func checkOfferFlags(userID int, offerID int, offer *models.Offer) ([]string, error) {
+	flags := []string{}
+
+	// Duplicate: same member, company and role submitted recently
+	var duplicate bool
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT EXISTS(
+			SELECT 1 FROM offers
+			WHERE user_id = $1 AND id <> $2 AND status <> $3
+			  AND LOWER(company) = LOWER($4) AND LOWER(role) = LOWER($5)
+			  AND created_at > NOW() - make_interval(days => $6)
+		)`,
+		userID, offerID, models.OfferRejected, offer.Company, offer.Role, offerDuplicateWindowDays).Scan(&duplicate)
+	if err != nil {
+		return nil, err
+	}
+	if duplicate {
+		flags = append(flags, "duplicate")
+	}
+
+	// Outlier: far from what other members reported for the same company, compared in USD
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT hourly_rate, currency FROM offers
+		 WHERE id <> $1 AND status = $2 AND LOWER(company) = LOWER($3) AND offer_type = $4 AND hourly_rate > 0`,
+		offerID, models.OfferApproved, offer.Company, offer.OfferType)
+	if err != nil {
+		return nil, err
+	}
+	rates := []float64{}
+	for rows.Next() {
+		var rate float64
+		var code string
+		if err := rows.Scan(&rate, &code); err != nil {
+			continue
+		}
+		if usd, ok := currency.ToUSD(rate, code); ok {
+			rates = append(rates, usd)
+		}
+	}
+	rows.Close()
+
+	hourlyUSD, _ := currency.ToUSD(offer.HourlyRate, offer.Currency)
+	if len(rates) >= offerOutlierMinSamples && hourlyUSD > 0 {
+		sort.Float64s(rates)
+		median := percentile(rates, 50)
+		if hourlyUSD > median*offerOutlierFactor || hourlyUSD < median/offerOutlierFactor {
+			flags = append(flags, "outlier")
+		}
+	}
+
+	// New account: created recently (legacy accounts have no created_at)
+	var newAccount bool
+	err = db.Pool.QueryRow(context.Background(),
+		`SELECT COALESCE(created_at > NOW() - make_interval(days => $2), FALSE) FROM users WHERE id = $1`,
+		userID, envInt("OFFER_NEW_ACCOUNT_DAYS", defaultOfferNewAccountDays)).Scan(&newAccount)
+	if err != nil {
+		return nil, err
+	}
+	if newAccount {
+		flags = append(flags, "new_account")
+	}
+
+	return flags, nil
+}
+
+// POST /api/offers/:id/report
+// Body: { "reason": "Looks like a typo, $900/hr" }
+func ReportOffer(c *fiber.Ctx) error {
+	/*
+		Reports an offer as suspicious
+		Offers that collect enough open reports are pulled from the public list until an admin reviews them
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized/No JWT found"})
+	}
+
+	offerID := c.Params("id")
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+	if strings.TrimSpace(body.Reason) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Please give a reason for the report"})
+	}
+
+	var status string
+	var ownerID int
+	err = db.Pool.QueryRow(context.Background(),
+		`SELECT status, COALESCE(user_id, 0) FROM offers WHERE id = $1`, offerID).Scan(&status, &ownerID)
+	if err != nil || status != models.OfferApproved {
+		return c.Status(404).JSON(fiber.Map{"error": "Offer not found"})
+	}
+	if ownerID == claims.UserID {
+		return c.Status(400).JSON(fiber.Map{"error": "You can't report your own offer"})
+	}
+
+	result, err := db.Pool.Exec(context.Background(),
+		`INSERT INTO offer_reports (offer_id, user_id, reason) VALUES ($1, $2, $3)
+		 ON CONFLICT (offer_id, user_id) DO NOTHING`,
+		offerID, claims.UserID, body.Reason)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+	}
+	if result.RowsAffected() == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "You already reported this offer"})
+	}
+
+	// Hold the offer for review once enough members report it
+	_, err = db.Pool.Exec(context.Background(),
+		`UPDATE offers SET status = $1, flag_reasons = array_append(flag_reasons, 'reported')
+		 WHERE id = $2 AND status = $3
+		   AND (SELECT COUNT(*) FROM offer_reports WHERE offer_id = $2 AND resolved_at IS NULL) >= $4`,
+		models.OfferPending, offerID, models.OfferApproved, envInt("OFFER_REPORT_THRESHOLD", defaultOfferReportThreshold))
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Thanks, an admin will review this offer"})
+}
+
+// GET /api/admin/offers/moderation (ADMIN ONLY)
+func GetOfferModerationQueue(c *fiber.Ctx) error {
+	/*
+		Gets offers waiting for review: held submissions and offers with open reports
+		Includes the submitter and every open report so admins can decide
+	*/
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT `+offerSelectColumns+`, o.user_id, u.name, u.email
+		 FROM offers o
+		 LEFT JOIN users u ON u.id = o.user_id
+		 WHERE o.status = $1
+		    OR EXISTS(SELECT 1 FROM offer_reports r WHERE r.offer_id = o.id AND r.resolved_at IS NULL)
+		 ORDER BY o.created_at`, models.OfferPending)
+	if err != nil {
+		log.Println("DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	defer rows.Close()
+
+	type QueueItem struct {
+		models.Offer
+		SubmitterEmail string               `json:"submitter_email"`
+		Reports        []models.OfferReport `json:"reports"`
+	}
+
+	queue := []QueueItem{}
+	index := map[int]int{}
+	for rows.Next() {
+		var item QueueItem
+		var name, email *string
+		if err := rows.Scan(append(offerScanFields(&item.Offer), &item.UserID, &name, &email)...); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		applyUSDAmounts(&item.Offer)
+		item.CompanyLogoURL = logoURL(c, item.CompanyLogoURL, item.CompanyDomain, item.Company)
+		if name != nil {
+			item.SubmitterName = *name
+		}
+		if email != nil {
+			item.SubmitterEmail = *email
+		}
+		item.Reports = []models.OfferReport{}
+		index[item.ID] = len(queue)
+		queue = append(queue, item)
+	}
+	rows.Close()
+
+	reportRows, err := db.Pool.Query(context.Background(),
+		`SELECT id, offer_id, user_id, reason, created_at, resolved_at
+		 FROM offer_reports WHERE resolved_at IS NULL ORDER BY created_at`)
+	if err != nil {
+		log.Println("DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	defer reportRows.Close()
+
+	for reportRows.Next() {
+		var report models.OfferReport
+		if err := reportRows.Scan(&report.ID, &report.OfferID, &report.UserID, &report.Reason, &report.CreatedAt, &report.ResolvedAt); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		if i, ok := index[report.OfferID]; ok {
+			queue[i].Reports = append(queue[i].Reports, report)
+		}
+	}
+
+	return c.JSON(queue)
+}
+
+// Helper function to apply a moderation decision and close the offer's reports
+func moderateOffer(c *fiber.Ctx, status string, message string) error {
+	offerID := c.Params("id")
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(context.Background())
+
+	// Approving settles the flags, so later reports start from a clean slate
+	result, err := tx.Exec(context.Background(),
+		`UPDATE offers SET status = $1, flag_reasons = CASE WHEN $1 = $3 THEN '{}' ELSE flag_reasons END WHERE id = $2`,
+		status, offerID, models.OfferApproved)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+	if result.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Offer not found"})
+	}
+
+	_, err = tx.Exec(context.Background(),
+		`UPDATE offer_reports SET resolved_at = $1 WHERE offer_id = $2 AND resolved_at IS NULL`,
+		time.Now(), offerID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+
+	return c.JSON(fiber.Map{"message": message})
+}
+
+// POST /api/admin/offers/:id/approve (ADMIN ONLY)
+func ApproveOffer(c *fiber.Ctx) error {
+	/*
+		Makes a held or reported offer public and resolves its reports
+	*/
+	return moderateOffer(c, models.OfferApproved, "Offer approved")
+}
+
+// POST /api/admin/offers/:id/reject (ADMIN ONLY)
+func RejectOffer(c *fiber.Ctx) error {
+	/*
+		Hides a held or reported offer and resolves its reports
+		The owner can still see and edit it through /api/offers/mine
+	*/
+	return moderateOffer(c, models.OfferRejected, "Offer rejected")
+}
+
+// DELETE /api/admin/offers/:id (ADMIN ONLY)
+func AdminDeleteOffer(c *fiber.Ctx) error {
+	/*
+		Permanently deletes any offer
+		Admin only
+	*/
+	result, err := db.Pool.Exec(context.Background(),
+		`DELETE FROM offers WHERE id = $1`, c.Params("id"))
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database delete failed"})
+	}
+
+	if result.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Offer not found"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Offer deleted successfully"})
+}
@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	offerDateLayout       = "2006-01-02"
+	maxInterviewRounds    = 15
+	maxRoundTopics        = 10
+	maxRoundNotesLength   = 2000
+	topInterviewTopicsLen = 5
+)
+
+// Timeline stages in the order they happen, keyed by the names used in aggregates
+var timelineStages = []string{"applied", "oa", "phone_screen", "onsite", "offer", "decision"}
+
+// Helper function to list a timeline's dates in stage order
+func timelineDates(t *models.OfferTimeline) []*string {
+	return []*string{&t.AppliedOn, &t.OAOn, &t.PhoneScreenOn, &t.OnsiteOn, &t.OfferOn, &t.DecisionOn}
+}
+
+// Helper function to parse a timeline's dates, nil for stages that were skipped
+func parseTimeline(t *models.OfferTimeline) ([]*time.Time, string) {
+	parsed := []*time.Time{}
+	var last *time.Time
+	for i, value := range timelineDates(t) {
+		*value = strings.TrimSpace(*value)
+		if *value == "" {
+			parsed = append(parsed, nil)
+			continue
+		}
+		date, err := time.Parse(offerDateLayout, *value)
+		if err != nil {
+			return nil, "Dates must be formatted as YYYY-MM-DD"
+		}
+		if date.After(time.Now()) {
+			return nil, "Timeline dates cannot be in the future"
+		}
+		if last != nil && date.Before(*last) {
+			return nil, "The " + timelineStages[i] + " date is before an earlier stage"
+		}
+		last = &date
+		parsed = append(parsed, &date)
+	}
+	return parsed, ""
+}
+
+// Helper function to validate interview rounds and clean up their topics
+func normalizeRounds(rounds []models.InterviewRound) string {
+	if len(rounds) > maxInterviewRounds {
+		return "Too many interview rounds"
+	}
+	for i := range rounds {
+		round := &rounds[i]
+		switch round.Kind {
+		case models.RoundOnlineAssessment, models.RoundPhoneScreen, models.RoundTechnical, models.RoundBehavioral,
+			models.RoundSystemDesign, models.RoundOnsite, models.RoundOther:
+		default:
+			return "Invalid interview round kind: " + round.Kind
+		}
+		// 0 means the member didn't rate it
+		if round.Difficulty < 0 || round.Difficulty > 5 {
+			return "Difficulty must be between 1 and 5"
+		}
+		if len(round.Notes) > maxRoundNotesLength {
+			return "Round notes are too long"
+		}
+
+		// Drop empty and duplicate topics so aggregates count each once
+		seen := map[string]bool{}
+		topics := []string{}
+		for _, topic := range round.Topics {
+			topic = strings.TrimSpace(topic)
+			key := strings.ToLower(topic)
+			if topic == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			topics = append(topics, topic)
+		}
+		if len(topics) > maxRoundTopics {
+			return "Too many topics in one round"
+		}
+		round.Topics = topics
+		round.Position = i
+	}
+	return ""
+}
+
+// Helper function to load the timeline and rounds shared with an offer
+func loadOfferProcess(offerID int) (models.OfferProcess, error) {
+	process := models.OfferProcess{OfferID: offerID, Rounds: []models.InterviewRound{}}
+
+	var dates [6]*time.Time
+	var decision string
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT applied_on, oa_on, phone_screen_on, onsite_on, offer_on, decision_on, decision
+		 FROM offer_timelines WHERE offer_id = $1`, offerID).
+		Scan(&dates[0], &dates[1], &dates[2], &dates[3], &dates[4], &dates[5], &decision)
+	if err == nil {
+		timeline := &models.OfferTimeline{Decision: decision}
+		for i, value := range timelineDates(timeline) {
+			if dates[i] != nil {
+				*value = dates[i].Format(offerDateLayout)
+			}
+		}
+		process.Timeline = timeline
+	} else if err != pgx.ErrNoRows {
+		return process, err
+	}
+
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT id, position, kind, difficulty, topics, notes
+		 FROM offer_interview_rounds WHERE offer_id = $1 ORDER BY position, id`, offerID)
+	if err != nil {
+		return process, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var round models.InterviewRound
+		if err := rows.Scan(&round.ID, &round.Position, &round.Kind, &round.Difficulty, &round.Topics, &round.Notes); err != nil {
+			return process, err
+		}
+		process.Rounds = append(process.Rounds, round)
+	}
+	return process, rows.Err()
+}
+
+// GET /api/offers/:id/process
+func GetOfferProcess(c *fiber.Ctx) error {
+	/*
+		Gets the application timeline and interview rounds shared with a public offer
+	*/
+	offerID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid offer ID"})
+	}
+
+	var status string
+	err = db.Pool.QueryRow(context.Background(),
+		`SELECT status FROM offers WHERE id = $1`, offerID).Scan(&status)
+	if err != nil || status != models.OfferApproved {
+		return c.Status(404).JSON(fiber.Map{"error": "Offer not found"})
+	}
+
+	process, err := loadOfferProcess(offerID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	return c.JSON(process)
+}
+
+// PUT /api/offers/mine/:id/process
+// Body: { "timeline": { "applied_on": "2025-09-01", "offer_on": "2025-10-20" }, "rounds": [{ "kind": "technical", "difficulty": 3, "topics": ["graphs"] }] }
+func SaveOfferProcess(c *fiber.Ctx) error {
+	/*
+		Replaces the timeline and interview rounds attached to one of the current user's offers
+		Sending no timeline and no rounds clears the process
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized/No JWT found"})
+	}
+
+	offerID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid offer ID"})
+	}
+
+	var body models.OfferProcess
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+
+	var dates []*time.Time
+	if body.Timeline != nil {
+		var msg string
+		if dates, msg = parseTimeline(body.Timeline); msg != "" {
+			return c.Status(400).JSON(fiber.Map{"error": msg})
+		}
+		switch body.Timeline.Decision {
+		case "", "accepted", "declined", "pending":
+		default:
+			return c.Status(400).JSON(fiber.Map{"error": "Decision must be accepted, declined or pending"})
+		}
+	}
+	if msg := normalizeRounds(body.Rounds); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(context.Background())
+
+	var owned bool
+	err = tx.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM offers WHERE id = $1 AND user_id = $2)`, offerID, claims.UserID).Scan(&owned)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	if !owned {
+		return c.Status(404).JSON(fiber.Map{"error": "Offer not found or unauthorized"})
+	}
+
+	if body.Timeline == nil {
+		_, err = tx.Exec(context.Background(), `DELETE FROM offer_timelines WHERE offer_id = $1`, offerID)
+	} else {
+		_, err = tx.Exec(context.Background(),
+			`INSERT INTO offer_timelines (offer_id, applied_on, oa_on, phone_screen_on, onsite_on, offer_on, decision_on, decision)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			 ON CONFLICT (offer_id) DO UPDATE SET applied_on = $2, oa_on = $3, phone_screen_on = $4, onsite_on = $5,
+			 	offer_on = $6, decision_on = $7, decision = $8`,
+			offerID, dates[0], dates[1], dates[2], dates[3], dates[4], dates[5], body.Timeline.Decision)
+	}
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+
+	_, err = tx.Exec(context.Background(), `DELETE FROM offer_interview_rounds WHERE offer_id = $1`, offerID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+	for _, round := range body.Rounds {
+		_, err = tx.Exec(context.Background(),
+			`INSERT INTO offer_interview_rounds (offer_id, position, kind, difficulty, topics, notes)
+			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			offerID, round.Position, round.Kind, round.Difficulty, round.Topics, round.Notes)
+		if err != nil {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+
+	process, err := loadOfferProcess(offerID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	return c.JSON(process)
+}
+
+// GET /api/offers/process/stats?company=Google
+func GetCompanyProcessStats(c *fiber.Ctx) error {
+	/*
+		Aggregates shared interview processes per company
+		Returns the median days from applying to each stage, how often each round kind comes up,
+		the average number of rounds, average difficulty and most common topics
+		Companies with fewer processes than the minimum group size are omitted
+		Optional company query parameter to only return one company
+	*/
+	type companyBucket struct {
+		label        string
+		offers       map[int]bool
+		daysToStage  map[string][]float64
+		roundsByKind map[string]map[int]bool
+		roundCounts  map[int]int
+		difficulties []float64
+		topics       map[string]int
+		topicLabels  map[string]string
+	}
+	buckets := map[string]*companyBucket{}
+	bucketFor := func(company string, offerID int) *companyBucket {
+		key := strings.ToLower(strings.TrimSpace(company))
+		b, ok := buckets[key]
+		if !ok {
+			b = &companyBucket{
+				label:        strings.TrimSpace(company),
+				offers:       map[int]bool{},
+				daysToStage:  map[string][]float64{},
+				roundsByKind: map[string]map[int]bool{},
+				roundCounts:  map[int]int{},
+				topics:       map[string]int{},
+				topicLabels:  map[string]string{},
+			}
+			buckets[key] = b
+		}
+		b.offers[offerID] = true
+		return b
+	}
+
+	company := strings.TrimSpace(c.Query("company"))
+
+	// Timelines
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT o.id, o.company, t.applied_on, t.oa_on, t.phone_screen_on, t.onsite_on, t.offer_on, t.decision_on
+		 FROM offer_timelines t
+		 JOIN offers o ON o.id = t.offer_id
+		 WHERE o.status = $1 AND ($2 = '' OR LOWER(o.company) = LOWER($2))`,
+		models.OfferApproved, company)
+	if err != nil {
+		log.Println("DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	for rows.Next() {
+		var offerID int
+		var name string
+		var dates [6]*time.Time
+		if err := rows.Scan(&offerID, &name, &dates[0], &dates[1], &dates[2], &dates[3], &dates[4], &dates[5]); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		b := bucketFor(name, offerID)
+		if dates[0] == nil {
+			continue
+		}
+		for i := 1; i < len(dates); i++ {
+			if dates[i] != nil {
+				b.daysToStage[timelineStages[i]] = append(b.daysToStage[timelineStages[i]], dates[i].Sub(*dates[0]).Hours()/24)
+			}
+		}
+	}
+	rows.Close()
+
+	// Interview rounds
+	rows, err = db.Pool.Query(context.Background(),
+		`SELECT o.id, o.company, r.kind, r.difficulty, r.topics
+		 FROM offer_interview_rounds r
+		 JOIN offers o ON o.id = r.offer_id
+		 WHERE o.status = $1 AND ($2 = '' OR LOWER(o.company) = LOWER($2))`,
+		models.OfferApproved, company)
+	if err != nil {
+		log.Println("DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	for rows.Next() {
+		var offerID, difficulty int
+		var name, kind string
+		var topics []string
+		if err := rows.Scan(&offerID, &name, &kind, &difficulty, &topics); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		b := bucketFor(name, offerID)
+		if b.roundsByKind[kind] == nil {
+			b.roundsByKind[kind] = map[int]bool{}
+		}
+		b.roundsByKind[kind][offerID] = true
+		b.roundCounts[offerID]++
+		if difficulty > 0 {
+			b.difficulties = append(b.difficulties, float64(difficulty))
+		}
+		for _, topic := range topics {
+			key := strings.ToLower(topic)
+			b.topics[key]++
+			if _, ok := b.topicLabels[key]; !ok {
+				b.topicLabels[key] = topic
+			}
+		}
+	}
+	rows.Close()
+
+	minGroup := offerStatsMinGroup()
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	stats := []models.CompanyProcessStats{}
+	for _, b := range buckets {
+		count := len(b.offers)
+		if count < minGroup {
+			continue
+		}
+
+		result := models.CompanyProcessStats{
+			Company:           b.label,
+			Count:             count,
+			MedianDaysToStage: map[string]float64{},
+			StageFrequency:    map[string]float64{},
+			TopTopics:         []string{},
+		}
+
+		// A stage needs as many data points as a group so one member's dates can't be singled out
+		for stage, days := range b.daysToStage {
+			if len(days) < minGroup {
+				continue
+			}
+			sort.Float64s(days)
+			result.MedianDaysToStage[stage] = round(percentile(days, 50))
+		}
+
+		for kind, offers := range b.roundsByKind {
+			result.StageFrequency[kind] = round(float64(len(offers)) / float64(count))
+		}
+
+		if len(b.roundCounts) > 0 {
+			total := 0
+			for _, n := range b.roundCounts {
+				total += n
+			}
+			result.AverageRounds = round(float64(total) / float64(len(b.roundCounts)))
+		}
+
+		if len(b.difficulties) > 0 {
+			sum := 0.0
+			for _, d := range b.difficulties {
+				sum += d
+			}
+			result.AverageDifficulty = round(sum / float64(len(b.difficulties)))
+		}
+
+		topics := make([]string, 0, len(b.topics))
+		for key := range b.topics {
+			topics = append(topics, key)
+		}
+		sort.Slice(topics, func(i, j int) bool {
+			if b.topics[topics[i]] != b.topics[topics[j]] {
+				return b.topics[topics[i]] > b.topics[topics[j]]
+			}
+			return topics[i] < topics[j]
+		})
+		for i := 0; i < len(topics) && i < topInterviewTopicsLen; i++ {
+			result.TopTopics = append(result.TopTopics, b.topicLabels[topics[i]])
+		}
+
+		stats = append(stats, result)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Company < stats[j].Company
+	})
+
+	return c.JSON(fiber.Map{"min_group_size": minGroup, "companies": stats})
+}
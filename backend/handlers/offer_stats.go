@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/currency"
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Default smallest group we report on so single offers can't be traced back to a member
+const defaultOfferStatsMinGroup = 3
+
+// Helper function to read the minimum group size from OFFER_STATS_MIN_GROUP
+func offerStatsMinGroup() int {
+	if value, err := strconv.Atoi(os.Getenv("OFFER_STATS_MIN_GROUP")); err == nil && value > 0 {
+		return value
+	}
+	return defaultOfferStatsMinGroup
+}
+
+// Helper function to compute a percentile (0-100) of sorted values using linear interpolation
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	weight := rank - float64(lower)
+	return sorted[lower]*(1-weight) + sorted[upper]*weight
+}
+
+// Helper function to summarize a set of rates, ignoring missing (zero) values
+// Left empty when fewer than minGroup offers have the rate, so one member's pay can't be read off it
+func summarizeRates(values []float64, minGroup int) models.RateSummary {
+	sorted := []float64{}
+	for _, v := range values {
+		if v > 0 {
+			sorted = append(sorted, v)
+		}
+	}
+	if len(sorted) == 0 || len(sorted) < minGroup {
+		return models.RateSummary{}
+	}
+	sort.Float64s(sorted)
+
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	return models.RateSummary{
+		Count:  len(sorted),
+		Min:    round(sorted[0]),
+		P25:    round(percentile(sorted, 25)),
+		Median: round(percentile(sorted, 50)),
+		P75:    round(percentile(sorted, 75)),
+		Max:    round(sorted[len(sorted)-1]),
+	}
+}
+
+// Helper function to name the season an offer falls in
+func offerSeason(t time.Time) string {
+	switch t.Month() {
+	case time.March, time.April, time.May:
+		return "Spring"
+	case time.June, time.July, time.August:
+		return "Summer"
+	case time.September, time.October, time.November:
+		return "Fall"
+	default:
+		return "Winter"
+	}
+}
+
+// Helper function to pick the season and year an offer is for
+// Falls back to when it was submitted for offers posted before season/year were collected
+func offerTerm(offer models.Offer) (string, int) {
+	if offer.Season != "" && offer.Year != 0 {
+		return offer.Season, offer.Year
+	}
+	if offer.Year != 0 {
+		return offerSeason(offer.CreatedAt), offer.Year
+	}
+	return offerSeason(offer.CreatedAt), offer.CreatedAt.Year()
+}
+
+// Helper function to order season groups like "Spring 2025" chronologically
+func sortSeasonGroups(groups []models.OfferGroupStats) {
+	order := map[string]int{"Winter": 0, "Spring": 1, "Summer": 2, "Fall": 3}
+	sortKey := func(label string) string {
+		season, year, _ := strings.Cut(label, " ")
+		return fmt.Sprintf("%s-%d", year, order[season])
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return sortKey(groups[i].Key) < sortKey(groups[j].Key)
+	})
+}
+
+// Helper function to group offers by a key and summarize each group
+func groupOfferStats(offers []models.Offer, minGroup int, keyFn func(models.Offer) string) []models.OfferGroupStats {
+	type bucket struct {
+		label   string
+		hourly  []float64
+		monthly []float64
+		total   []float64
+	}
+	buckets := map[string]*bucket{}
+
+	for _, offer := range offers {
+		label := strings.TrimSpace(keyFn(offer))
+		if label == "" {
+			continue
+		}
+		// Group case-insensitively so "google" and "Google" count together
+		key := strings.ToLower(label)
+		if _, ok := buckets[key]; !ok {
+			buckets[key] = &bucket{label: label}
+		}
+		buckets[key].hourly = append(buckets[key].hourly, offer.HourlyRate)
+		buckets[key].monthly = append(buckets[key].monthly, offer.MonthlyRate)
+		buckets[key].total = append(buckets[key].total, offer.TotalComp)
+	}
+
+	groups := []models.OfferGroupStats{}
+	for _, b := range buckets {
+		if len(b.hourly) < minGroup {
+			continue
+		}
+		groups = append(groups, models.OfferGroupStats{
+			Key:     b.label,
+			Count:   len(b.hourly),
+			Hourly:  summarizeRates(b.hourly, minGroup),
+			Monthly: summarizeRates(b.monthly, minGroup),
+			Total:   summarizeRates(b.total, minGroup),
+		})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Key < groups[j].Key
+	})
+	return groups
+}
+
+// GET /api/offers/stats
+func GetOfferStats(c *fiber.Ctx) error {
+	/*
+		Aggregates offers into compensation statistics
+		Returns count, min, p25, median, p75 and max hourly/monthly rates and total comp per company, role,
+		offer type, location, season and year
+		Amounts are normalized to USD with the current exchange rates
+		Groups smaller than the minimum group size are omitted, as are rates fewer offers in a group have
+	*/
+	rows, err := db.Pool.Query(context.Background(),
+		"SELECT company, role, offer_type, currency, hourly_rate, monthly_rate, total_comp, location, season, year, created_at FROM offers WHERE status = $1",
+		models.OfferApproved)
+	if err != nil {
+		log.Println("DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	defer rows.Close()
+
+	offers := []models.Offer{}
+	for rows.Next() {
+		var offer models.Offer
+		if err := rows.Scan(&offer.Company, &offer.Role, &offer.OfferType, &offer.Currency, &offer.HourlyRate, &offer.MonthlyRate, &offer.TotalComp, &offer.Location, &offer.Season, &offer.Year, &offer.CreatedAt); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+
+		// Compare everything in USD, skipping offers in currencies we no longer have a rate for
+		if !currency.Supported(offer.Currency) {
+			continue
+		}
+		applyUSDAmounts(&offer)
+		offer.HourlyRate, offer.MonthlyRate, offer.TotalComp = offer.HourlyRateUSD, offer.MonthlyRateUSD, offer.TotalCompUSD
+		offers = append(offers, offer)
+	}
+
+	minGroup := offerStatsMinGroup()
+	stats := models.OfferStats{
+		MinGroupSize: minGroup,
+		TotalOffers:  len(offers),
+		ByCompany:    groupOfferStats(offers, minGroup, func(o models.Offer) string { return o.Company }),
+		ByRole:       groupOfferStats(offers, minGroup, func(o models.Offer) string { return o.Role }),
+		ByOfferType:  groupOfferStats(offers, minGroup, func(o models.Offer) string { return o.OfferType }),
+		ByLocation:   groupOfferStats(offers, minGroup, func(o models.Offer) string { return o.Location }),
+		BySeason: groupOfferStats(offers, minGroup, func(o models.Offer) string {
+			season, year := offerTerm(o)
+			return fmt.Sprintf("%s %d", season, year)
+		}),
+		ByYear: groupOfferStats(offers, minGroup, func(o models.Offer) string {
+			_, year := offerTerm(o)
+			return strconv.Itoa(year)
+		}),
+	}
+
+	// Trends read oldest to newest
+	sortSeasonGroups(stats.BySeason)
+	sort.Slice(stats.ByYear, func(i, j int) bool { return stats.ByYear[i].Key < stats.ByYear[j].Key })
+
+	if overall := groupOfferStats(offers, minGroup, func(models.Offer) string { return "all" }); len(overall) > 0 {
+		stats.Overall = overall[0]
+	}
+
+	return c.JSON(stats)
+}
@@ -2,25 +2,65 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"log"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/KerlynD/CFA_Member_Profile/backend/currency"
 	"github.com/KerlynD/CFA_Member_Profile/backend/db"
 	"github.com/KerlynD/CFA_Member_Profile/backend/models"
 	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 )
 
+// Offer columns shared by every offer query, in the order offerScanFields expects
+// Queries alias the offers table as "o"
+const offerSelectColumns = `o.id, o.show_name, o.company_id, o.company, o.company_logo_url,
+	COALESCE((SELECT c.domain FROM companies c WHERE c.id = o.company_id), ''), o.role, o.level, o.offer_type, o.season, o.year,
+	o.work_mode, o.location, o.return_offer, o.currency, o.hourly_rate, o.monthly_rate, o.base_salary, o.signing_bonus, o.relocation,
+	o.housing_stipend, o.stock_amount, o.stock_vesting, o.vesting_years, o.total_comp, o.created_at, o.status, o.flag_reasons`
+
+// Helper function returning scan destinations matching offerSelectColumns
+func offerScanFields(offer *models.Offer) []any {
+	return []any{&offer.ID, &offer.ShowName, &offer.CompanyID, &offer.Company, &offer.CompanyLogoURL, &offer.CompanyDomain, &offer.Role, &offer.Level, &offer.OfferType, &offer.Season, &offer.Year,
+		&offer.WorkMode, &offer.Location, &offer.ReturnOffer, &offer.Currency, &offer.HourlyRate, &offer.MonthlyRate, &offer.BaseSalary, &offer.SigningBonus, &offer.Relocation,
+		&offer.HousingStipend, &offer.StockAmount, &offer.StockVesting, &offer.VestingYears, &offer.TotalComp, &offer.CreatedAt, &offer.Status, &offer.FlagReasons}
+}
+
+// Helper function to fill in an offer's USD amounts using the current exchange rates
+// Amounts stay at zero if the currency has no rate anymore
+func applyUSDAmounts(offer *models.Offer) {
+	rate, ok := currency.Rate(offer.Currency)
+	if !ok {
+		return
+	}
+	round := func(v float64) float64 { return math.Round(v*rate*100) / 100 }
+	offer.HourlyRateUSD = round(offer.HourlyRate)
+	offer.MonthlyRateUSD = round(offer.MonthlyRate)
+	offer.BaseSalaryUSD = round(offer.BaseSalary)
+	offer.TotalCompUSD = math.Round(offer.TotalComp * rate)
+}
+
 // GET /api/offers
 func GetOffers(c *fiber.Ctx) error {
 	/*
 		Gets all offers from the database
 		Returns a JSON array of all offers
+		Offers are anonymous unless the submitter opted in to show their name
+		Offers held for moderation are left out
 	*/
 
 	// Query the database for all offers
 	rows, err := db.Pool.Query(context.Background(),
-		"SELECT id, user_id, company, company_logo_url, role, offer_type, hourly_rate, monthly_rate, location, created_at FROM offers ORDER BY created_at DESC")
+		`SELECT `+offerSelectColumns+`, o.user_id, u.name
+		 FROM offers o
+		 LEFT JOIN users u ON u.id = o.user_id
+		 WHERE o.status = $1
+		 ORDER BY o.created_at DESC`, models.OfferApproved)
 	if err != nil {
 		log.Println("DB Error: ", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
@@ -31,23 +71,138 @@ func GetOffers(c *fiber.Ctx) error {
 	offers := []models.Offer{}
 	for rows.Next() {
 		var offer models.Offer
+		var userID int
+		var name *string
 
-		err := rows.Scan(&offer.ID, &offer.UserID, &offer.Company, &offer.CompanyLogoURL, &offer.Role, &offer.OfferType, &offer.HourlyRate, &offer.MonthlyRate, &offer.Location, &offer.CreatedAt)
+		err := rows.Scan(append(offerScanFields(&offer), &userID, &name)...)
 
 		if err != nil {
 			log.Println("Scanner Error: ", err)
 			continue
 		}
+
+		// Never reveal who submitted an offer unless they asked for it
+		if offer.ShowName && name != nil {
+			offer.UserID = &userID
+			offer.SubmitterName = *name
+		}
+
+		applyUSDAmounts(&offer)
+		offer.CompanyLogoURL = logoURL(c, offer.CompanyLogoURL, offer.CompanyDomain, offer.Company)
+
+		// Moderation details are only for the owner and admins
+		offer.Status = ""
+		offer.FlagReasons = nil
 		offers = append(offers, offer)
 	}
 	return c.JSON(offers)
 }
 
+// Helper function to validate an offer submitted by a member and fill in derived fields
+// Returns an error message, or an empty string if the offer is valid
+func normalizeOffer(body *models.Offer) string {
+	body.Company = strings.TrimSpace(body.Company)
+	body.Role = strings.TrimSpace(body.Role)
+	if companyKey(body.Company) == "" || body.Role == "" {
+		return "Company and role are required"
+	}
+
+	for _, amount := range []float64{body.HourlyRate, body.MonthlyRate, body.BaseSalary, body.SigningBonus, body.Relocation, body.HousingStipend, body.StockAmount} {
+		if amount < 0 {
+			return "Compensation amounts cannot be negative"
+		}
+	}
+
+	switch body.WorkMode {
+	case "", "remote", "hybrid", "onsite":
+	default:
+		return "Work mode must be remote, hybrid or onsite"
+	}
+
+	switch body.Season {
+	case "", "Spring", "Summer", "Fall", "Winter":
+	default:
+		return "Season must be Spring, Summer, Fall or Winter"
+	}
+
+	if body.Year != 0 && (body.Year < 2000 || body.Year > time.Now().Year()+3) {
+		return "Year is out of range"
+	}
+
+	// Limits below are in USD so they apply the same way to every currency
+	body.Currency = currency.Normalize(body.Currency)
+	rate, ok := currency.Rate(body.Currency)
+	if !ok {
+		return "Unsupported currency"
+	}
+
+	// Rules per offer type
+	switch body.OfferType {
+	case models.OfferInternship:
+		// Interns are usually quoted hourly or monthly, derive the missing one
+		if body.HourlyRate <= 0 && body.MonthlyRate > 0 {
+			body.HourlyRate = body.MonthlyRate * 12 / 2080
+		}
+		if body.HourlyRate <= 0 {
+			return "Hourly rate must be greater than 0"
+		}
+		if body.HourlyRate*rate > 200.0 {
+			return "Hourly rate exceeds maximum allowed value"
+		}
+		if body.MonthlyRate <= 0 {
+			body.MonthlyRate = body.HourlyRate * 2080 / 12
+		}
+		if body.BaseSalary > 0 {
+			return "Internships are paid hourly or monthly, not with an annual base salary"
+		}
+
+	case models.OfferFullTime:
+		// Full-time offers are quoted as an annual base salary, derive the hourly rate for comparisons
+		if body.BaseSalary <= 0 && body.HourlyRate > 0 {
+			body.BaseSalary = body.HourlyRate * 2080
+		}
+		if body.BaseSalary <= 0 {
+			return "Base salary must be greater than 0"
+		}
+		if body.BaseSalary*rate > 1000000 {
+			return "Base salary exceeds maximum allowed value"
+		}
+		body.HourlyRate = body.BaseSalary / 2080
+		body.MonthlyRate = body.BaseSalary / 12
+		if body.StockAmount > 0 && body.VestingYears <= 0 {
+			return "Stock grants need the number of vesting years"
+		}
+		if body.VestingYears < 0 || body.VestingYears > 10 {
+			return "Vesting years is out of range"
+		}
+
+	default:
+		return "Offer type must be internship or full-time"
+	}
+
+	// First-year total compensation, full-time only
+	// Internships last a few months, annualizing them would put them next to full-time salaries
+	body.TotalComp = 0
+	if body.OfferType == models.OfferFullTime {
+		annualStock := 0.0
+		if body.StockAmount > 0 && body.VestingYears > 0 {
+			annualStock = body.StockAmount / float64(body.VestingYears)
+		}
+		body.TotalComp = math.Round(body.BaseSalary + annualStock + body.SigningBonus + body.Relocation + body.HousingStipend)
+	}
+
+	return ""
+}
+
 // POST /api/offers
 func AddOffer(c *fiber.Ctx) error {
 	/*
 		Adds a new offer to the database
-		Requires the offer's company, role, offer_type, hourly rate, monthly rate, and location to be in the request body
+		Requires the offer's company, role, offer_type and location to be in the request body
+		Internships need an hourly or monthly rate, full-time offers need a base salary
+		Optional season and year for internships
+		Optional currency (defaults to USD), level, work_mode, return_offer, signing_bonus, relocation, housing_stipend and stock details
+		Optional show_name to attach the submitter's name publicly
 	*/
 
 	// Get & Verify JWT to get the authenticated user's ID
@@ -70,37 +225,187 @@ func AddOffer(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
 	}
 
-	// Validate hourly rate
-	maxHourlyRate := 200.0
-	if body.OfferType == "full-time" {
-		maxHourlyRate = 480.0 // ~$1M/year
+	if msg := normalizeOffer(&body); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
 	}
 
-	if body.HourlyRate <= 0 {
-		return c.Status(400).JSON(fiber.Map{"error": "Hourly rate must be greater than 0"})
-	}
-	if body.HourlyRate > maxHourlyRate {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Hourly rate exceeds maximum allowed value",
-		})
+	// Match the company against the registry so spellings are grouped together
+	company, err := resolveCompany(body.Company)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
 	}
-
-	// Generate company logo URL using logo.dev
-	companyLogo := getCompanyLogoURL(body.Company)
+	body.Company = company.Name
+	body.CompanyID = &company.ID
+	companyLogo := company.LogoURL
 
 	// Set created_at to the current time server-side
 	body.CreatedAt = time.Now()
 
+	// Suspicious submissions are held for review instead of going public
+	flags, err := checkOfferFlags(claims.UserID, 0, &body)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	status := models.OfferApproved
+	if len(flags) > 0 {
+		status = models.OfferPending
+	}
+
 	// Insert the offer into the database using the authenticated user's ID
 	_, err = db.Pool.Exec(context.Background(),
-		`INSERT INTO offers (user_id, company, company_logo_url, role, offer_type, hourly_rate, monthly_rate, location, created_at) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-		claims.UserID, body.Company, companyLogo, body.Role, body.OfferType, body.HourlyRate, body.MonthlyRate, body.Location, body.CreatedAt)
+		`INSERT INTO offers (user_id, company, company_logo_url, role, offer_type, hourly_rate, monthly_rate, location, created_at, show_name, status, flag_reasons,
+			level, season, year, work_mode, return_offer, base_salary, signing_bonus, relocation, housing_stipend, stock_amount, stock_vesting, vesting_years, total_comp,
+			currency, company_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)`,
+		claims.UserID, body.Company, companyLogo, body.Role, body.OfferType, body.HourlyRate, body.MonthlyRate, body.Location, body.CreatedAt, body.ShowName,
+		status, flags,
+		body.Level, body.Season, body.Year, body.WorkMode, body.ReturnOffer, body.BaseSalary, body.SigningBonus, body.Relocation, body.HousingStipend,
+		body.StockAmount, body.StockVesting, body.VestingYears, body.TotalComp,
+		body.Currency, body.CompanyID)
 	if err != nil {
 		log.Println("Internal DB Error: ", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
 	}
-	return c.JSON(fiber.Map{"message": "Offer added successfully"})
+
+	if status == models.OfferPending {
+		return c.JSON(fiber.Map{"message": "Offer submitted and held for review", "status": status})
+	}
+	return c.JSON(fiber.Map{"message": "Offer added successfully", "status": status, "total_comp": body.TotalComp})
+}
+
+// GET /api/offers/mine
+func GetMyOffers(c *fiber.Ctx) error {
+	/*
+		Gets the offers submitted by the current user
+		Returns a JSON array of offers, including the ones shared anonymously or held for review
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized/No JWT found"})
+	}
+
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT `+offerSelectColumns+`, o.user_id
+		 FROM offers o WHERE o.user_id = $1 ORDER BY o.created_at DESC`, claims.UserID)
+	if err != nil {
+		log.Println("DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	defer rows.Close()
+
+	offers := []models.Offer{}
+	for rows.Next() {
+		var offer models.Offer
+		if err := rows.Scan(append(offerScanFields(&offer), &offer.UserID)...); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+		applyUSDAmounts(&offer)
+		offer.CompanyLogoURL = logoURL(c, offer.CompanyLogoURL, offer.CompanyDomain, offer.Company)
+		offers = append(offers, offer)
+	}
+	return c.JSON(offers)
 }
 
-// DELETE /api/offers/:id // TODO: Implement ADMIN ONLY
+// PUT /api/offers/mine/:id
+func UpdateMyOffer(c *fiber.Ctx) error {
+	/*
+		Updates an offer submitted by the current user
+		Requires the same fields as AddOffer in the request body
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized/No JWT found"})
+	}
+
+	var body models.Offer
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+
+	if msg := normalizeOffer(&body); msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+
+	offerID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid offer ID"})
+	}
+
+	// Match the company against the registry so spellings are grouped together
+	company, err := resolveCompany(body.Company)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	body.Company = company.Name
+	body.CompanyID = &company.ID
+	companyLogo := company.LogoURL
+
+	// Edits go through the same checks as new submissions
+	flags, err := checkOfferFlags(claims.UserID, offerID, &body)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	status := models.OfferApproved
+	if len(flags) > 0 {
+		status = models.OfferPending
+	}
+
+	// Only approved offers can stay approved, the owner's edit never approves an offer on its own
+	// Pending offers keep their flags and open reports for an admin, rejected ones go back to the queue
+	var savedStatus string
+	err = db.Pool.QueryRow(context.Background(),
+		`UPDATE offers
+		 SET company=$1, company_logo_url=$2, role=$3, offer_type=$4, hourly_rate=$5, monthly_rate=$6, location=$7, show_name=$8,
+		     status = CASE WHEN status = $9 THEN $10 ELSE $11 END,
+		     flag_reasons = CASE WHEN status = $9 THEN $12 ELSE ARRAY(SELECT DISTINCT unnest(flag_reasons || $12::text[])) END,
+		     level=$13, season=$14, year=$15, work_mode=$16, return_offer=$17, base_salary=$18, signing_bonus=$19, relocation=$20,
+		     housing_stipend=$21, stock_amount=$22, stock_vesting=$23, vesting_years=$24, total_comp=$25, currency=$26, company_id=$27
+		 WHERE id=$28 AND user_id=$29
+		 RETURNING status`,
+		body.Company, companyLogo, body.Role, body.OfferType, body.HourlyRate, body.MonthlyRate, body.Location, body.ShowName,
+		models.OfferApproved, status, models.OfferPending, flags,
+		body.Level, body.Season, body.Year, body.WorkMode, body.ReturnOffer, body.BaseSalary, body.SigningBonus, body.Relocation,
+		body.HousingStipend, body.StockAmount, body.StockVesting, body.VestingYears, body.TotalComp, body.Currency, body.CompanyID,
+		offerID, claims.UserID).Scan(&savedStatus)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.Status(404).JSON(fiber.Map{"error": "Offer not found or unauthorized"})
+	}
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Offer updated successfully", "status": savedStatus})
+}
+
+// DELETE /api/offers/mine/:id
+func DeleteMyOffer(c *fiber.Ctx) error {
+	/*
+		Deletes an offer submitted by the current user
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized/No JWT found"})
+	}
+
+	result, err := db.Pool.Exec(context.Background(),
+		`DELETE FROM offers WHERE id=$1 AND user_id=$2`, c.Params("id"), claims.UserID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database delete failed"})
+	}
+
+	if result.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Offer not found or unauthorized"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Offer deleted successfully"})
+}
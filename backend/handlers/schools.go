@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Helper function to build the key school names are matched on
+// A leading "The" is ignored so "The City College of New York" matches "City College of New York"
+func schoolKey(name string) string {
+	name = strings.TrimSpace(name)
+	if len(name) > 4 && strings.EqualFold(name[:4], "the ") {
+		name = name[4:]
+	}
+	return nameKey(name)
+}
+
+var schoolRegistry = nameRegistry{aliasTable: "school_aliases", idColumn: "school_id", key: schoolKey}
+
+// Helper function to load a school by ID
+func loadSchool(id int) (models.School, error) {
+	school := models.School{ID: id}
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT name, domain, logo_url, campus, system, created_at FROM schools WHERE id = $1`, id).
+		Scan(&school.Name, &school.Domain, &school.LogoURL, &school.Campus, &school.System, &school.CreatedAt)
+	return school, err
+}
+
+// Helper function to map a school name typed by a member to a registered school
+// Tries exact aliases and registers a new school if nothing matches
+func resolveSchool(name string) (models.School, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if schoolKey(name) == "" {
+		return models.School{}, errors.New("school name is empty")
+	}
+
+	id, ok, err := schoolRegistry.match(name)
+	if err != nil {
+		return models.School{}, err
+	}
+	if ok {
+		return loadSchool(id)
+	}
+
+	err = db.Pool.QueryRow(context.Background(),
+		`INSERT INTO schools (name) VALUES ($1)
+		 ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		 RETURNING id`, name).Scan(&id)
+	if err != nil {
+		return models.School{}, err
+	}
+	if err := schoolRegistry.addAliases(id, name); err != nil {
+		return models.School{}, err
+	}
+	return loadSchool(id)
+}
+
+// Helper function to load schools by name with their aliases and member count, every school when limit is 0
+func loadSchools(system string, limit, offset int) ([]models.School, error) {
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT s.id, s.name, s.domain, s.logo_url, s.campus, s.system, s.created_at,
+			COALESCE((SELECT array_agg(a.alias ORDER BY a.alias) FROM school_aliases a WHERE a.school_id = s.id), '{}'),
+			(SELECT COUNT(DISTINCT eh.user_id) FROM education_history eh WHERE eh.school_id = s.id)
+		 FROM schools s
+		 WHERE $1 = '' OR LOWER(s.system) = LOWER($1)
+		 ORDER BY s.name
+		 LIMIT NULLIF($2, 0) OFFSET $3`, system, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schools := []models.School{}
+	for rows.Next() {
+		var school models.School
+		if err := rows.Scan(&school.ID, &school.Name, &school.Domain, &school.LogoURL, &school.Campus, &school.System,
+			&school.CreatedAt, &school.Aliases, &school.MemberCount); err != nil {
+			return nil, err
+		}
+		schools = append(schools, school)
+	}
+	return schools, rows.Err()
+}
+
+// GET /api/schools?q=queens&system=CUNY
+// GET /api/schools?system=CUNY&limit=50&offset=0
+func SearchSchools(c *fiber.Ctx) error {
+	/*
+		Autocompletes school names
+		Matches the start of any alias first, then close spellings
+		Optional system filter, e.g. CUNY or SUNY
+		Without a query, pages through schools by name (limit defaults to 50, at most 200)
+	*/
+	system := strings.TrimSpace(c.Query("system"))
+	query := schoolKey(c.Query("q"))
+	if query == "" {
+		limit := c.QueryInt("limit", defaultRegistryPageSize)
+		if limit < 1 || limit > maxRegistryPageSize {
+			limit = defaultRegistryPageSize
+		}
+		schools, err := loadSchools(system, limit, max(c.QueryInt("offset", 0), 0))
+		if err != nil {
+			log.Println("DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+		}
+		return c.JSON(schools)
+	}
+
+	schools, err := loadSchools(system, 0, 0)
+	if err != nil {
+		log.Println("DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+
+	type scored struct {
+		school models.School
+		score  float64
+	}
+	matches := []scored{}
+	for _, school := range schools {
+		if score := searchScore(query, school.Aliases); score >= fuzzyMatchThreshold {
+			matches = append(matches, scored{school, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	results := []models.School{}
+	for i := 0; i < len(matches) && i < registrySearchLimit; i++ {
+		results = append(results, matches[i].school)
+	}
+	return c.JSON(results)
+}
+
+// Helper function to read and clean up a school from a request body
+func parseSchoolBody(c *fiber.Ctx) (models.School, string) {
+	var body models.School
+	if err := c.BodyParser(&body); err != nil {
+		return body, "Invalid request body/JSON"
+	}
+	body.Name = strings.Join(strings.Fields(body.Name), " ")
+	body.Domain = strings.ToLower(strings.TrimSpace(body.Domain))
+	body.LogoURL = strings.TrimSpace(body.LogoURL)
+	body.Campus = strings.TrimSpace(body.Campus)
+	body.System = strings.ToUpper(strings.TrimSpace(body.System))
+	if schoolKey(body.Name) == "" {
+		return body, "School name is required"
+	}
+	return body, ""
+}
+
+// POST /api/admin/schools (ADMIN ONLY)
+// Body: { "name": "Queens College", "domain": "qc.cuny.edu", "campus": "Queens, NY", "system": "CUNY", "aliases": ["QC"] }
+func CreateSchool(c *fiber.Ctx) error {
+	/*
+		Registers a school ahead of members entering it
+	*/
+	body, msg := parseSchoolBody(c)
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+
+	// Refuse spellings that already belong to another school, merging is the way to combine them
+	for _, name := range append([]string{body.Name}, body.Aliases...) {
+		if _, ok, err := schoolRegistry.ownerOf(schoolKey(name)); err != nil {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+		} else if ok {
+			return c.Status(409).JSON(fiber.Map{"error": "A school named " + name + " already exists"})
+		}
+	}
+
+	var id int
+	err := db.Pool.QueryRow(context.Background(),
+		`INSERT INTO schools (name, domain, logo_url, campus, system) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		body.Name, body.Domain, body.LogoURL, body.Campus, body.System).Scan(&id)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+	}
+	for _, name := range append([]string{body.Name}, body.Aliases...) {
+		if err := schoolRegistry.addAliases(id, name); err != nil {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+		}
+	}
+
+	return c.JSON(fiber.Map{"message": "School created successfully", "id": id})
+}
+
+// PUT /api/admin/schools/:id (ADMIN ONLY)
+// Body: { "name": "Queens College", "domain": "qc.cuny.edu", "logo_url": "", "campus": "Queens, NY", "system": "CUNY" }
+func UpdateSchool(c *fiber.Ctx) error {
+	/*
+		Renames a school or changes its logo, campus or system
+		Education history pointing at it is updated to the new name and logo
+		The old name keeps resolving to the school
+	*/
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid school ID"})
+	}
+
+	body, msg := parseSchoolBody(c)
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{"error": msg})
+	}
+	body.ID = id
+	if owner, ok, err := schoolRegistry.ownerOf(schoolKey(body.Name)); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	} else if ok && owner != id {
+		return c.Status(409).JSON(fiber.Map{"error": "Another school already uses that name, merge them instead"})
+	}
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(context.Background())
+
+	result, err := tx.Exec(context.Background(),
+		`UPDATE schools SET name = $1, domain = $2, logo_url = $3, campus = $4, system = $5 WHERE id = $6`,
+		body.Name, body.Domain, body.LogoURL, body.Campus, body.System, id)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+	if result.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "School not found"})
+	}
+	_, err = tx.Exec(context.Background(),
+		`UPDATE education_history SET school_name = $1, school_logo_url = $2 WHERE school_id = $3`,
+		body.Name, body.LogoURL, id)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+
+	if err := schoolRegistry.addAliases(id, body.Name); err != nil {
+		log.Println("Internal DB Error: ", err)
+	}
+	return c.JSON(fiber.Map{"message": "School updated successfully"})
+}
+
+// POST /api/admin/schools/:id/aliases (ADMIN ONLY)
+// Body: { "alias": "QC" }
+func AddSchoolAlias(c *fiber.Ctx) error {
+	/*
+		Adds an alternate spelling that resolves to the school
+	*/
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid school ID"})
+	}
+
+	var body struct {
+		Alias string `json:"alias"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+	key := schoolKey(body.Alias)
+	if key == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Alias is required"})
+	}
+
+	if owner, ok, err := schoolRegistry.ownerOf(key); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	} else if ok && owner != id {
+		return c.Status(409).JSON(fiber.Map{"error": "That alias belongs to another school, merge them instead"})
+	}
+
+	_, err = db.Pool.Exec(context.Background(),
+		`INSERT INTO school_aliases (alias, school_id) VALUES ($1, $2) ON CONFLICT (alias) DO NOTHING`, key, id)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(400).JSON(fiber.Map{"error": "School not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Alias added successfully", "alias": key})
+}
+
+// DELETE /api/admin/schools/:id/aliases/:alias (ADMIN ONLY)
+func DeleteSchoolAlias(c *fiber.Ctx) error {
+	/*
+		Removes an alternate spelling from a school
+		The school's current name always stays an alias
+	*/
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid school ID"})
+	}
+	school, err := loadSchool(id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "School not found"})
+	}
+
+	alias := c.Params("alias")
+	if alias == schoolKey(school.Name) || alias == nameKey(school.Name) {
+		return c.Status(400).JSON(fiber.Map{"error": "Cannot remove the school's own name"})
+	}
+
+	result, err := db.Pool.Exec(context.Background(),
+		`DELETE FROM school_aliases WHERE alias = $1 AND school_id = $2`, alias, id)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database delete failed"})
+	}
+	if result.RowsAffected() == 0 {
+		return c.Status(404).JSON(fiber.Map{"error": "Alias not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Alias removed successfully"})
+}
+
+// POST /api/admin/schools/:id/merge (ADMIN ONLY)
+// Body: { "source_ids": [12, 40] }
+func MergeSchools(c *fiber.Ctx) error {
+	/*
+		Merges duplicate schools into the school in the URL
+		Education history and aliases of the duplicates move over and the duplicates are deleted
+	*/
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid school ID"})
+	}
+
+	var body struct {
+		SourceIDs []int `json:"source_ids"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+	sources := []int{}
+	for _, source := range body.SourceIDs {
+		if source != id {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "No schools to merge"})
+	}
+
+	target, err := loadSchool(id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "School not found"})
+	}
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(context.Background())
+
+	statements := []string{
+		`UPDATE education_history SET school_id = $1 WHERE school_id = ANY($2)`,
+		`UPDATE school_aliases SET school_id = $1 WHERE school_id = ANY($2)`,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(context.Background(), stmt, id, sources); err != nil {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+		}
+	}
+
+	// Keep a logo if only a duplicate had one
+	if target.Domain == "" && target.LogoURL == "" {
+		err = tx.QueryRow(context.Background(),
+			`SELECT domain, logo_url FROM schools
+			 WHERE id = ANY($1) AND (domain <> '' OR logo_url <> '')
+			 ORDER BY id LIMIT 1`, sources).Scan(&target.Domain, &target.LogoURL)
+		if err == nil {
+			_, err = tx.Exec(context.Background(),
+				`UPDATE schools SET domain = $1, logo_url = $2 WHERE id = $3`, target.Domain, target.LogoURL, id)
+		}
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+		}
+	}
+
+	result, err := tx.Exec(context.Background(), `DELETE FROM schools WHERE id = ANY($1)`, sources)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database delete failed"})
+	}
+
+	_, err = tx.Exec(context.Background(),
+		`UPDATE education_history SET school_name = $1, school_logo_url = $2 WHERE school_id = $3`,
+		target.Name, target.LogoURL, id)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database update failed"})
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Schools merged successfully", "merged": result.RowsAffected()})
+}
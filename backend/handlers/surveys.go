@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
+	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Helper function to load an event's survey with its questions
+func loadEventSurvey(eventID string) (*models.EventSurvey, error) {
+	var survey models.EventSurvey
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT id, event_id, title, created_at FROM event_surveys WHERE event_id = $1`, eventID).
+		Scan(&survey.ID, &survey.EventID, &survey.Title, &survey.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT id, position, kind, prompt, options, required
+		 FROM event_survey_questions WHERE survey_id = $1 ORDER BY position, id`, survey.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	survey.Questions = []models.SurveyQuestion{}
+	for rows.Next() {
+		var question models.SurveyQuestion
+		if err := rows.Scan(&question.ID, &question.Position, &question.Kind, &question.Prompt, &question.Options, &question.Required); err != nil {
+			return nil, err
+		}
+		survey.Questions = append(survey.Questions, question)
+	}
+	return &survey, rows.Err()
+}
+
+// PUT /api/admin/events/:id/survey (ADMIN ONLY)
+func SaveEventSurvey(c *fiber.Ctx) error {
+	/*
+		Creates or replaces the feedback survey attached to an event
+		Requires a title and at least one question in the request body
+		Questions cannot be changed once members have started responding
+	*/
+	eventID := c.Params("id")
+
+	var body struct {
+		Title     string                  `json:"title"`
+		Questions []models.SurveyQuestion `json:"questions"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+
+	if len(body.Questions) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "A survey needs at least one question"})
+	}
+	for _, question := range body.Questions {
+		if strings.TrimSpace(question.Prompt) == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "Every question needs a prompt"})
+		}
+		switch question.Kind {
+		case models.QuestionRating, models.QuestionText:
+		case models.QuestionMultipleChoice:
+			if len(question.Options) < 2 {
+				return c.Status(400).JSON(fiber.Map{"error": "Multiple choice questions need at least two options"})
+			}
+		default:
+			return c.Status(400).JSON(fiber.Map{"error": "Unknown question kind: " + question.Kind})
+		}
+	}
+
+	var exists bool
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM events WHERE id = $1)`, eventID).Scan(&exists)
+	if err != nil || !exists {
+		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
+	}
+
+	var responses int
+	err = db.Pool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM event_survey_responses r
+		 JOIN event_surveys s ON s.id = r.survey_id
+		 WHERE s.event_id = $1`, eventID).Scan(&responses)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	if responses > 0 {
+		return c.Status(409).JSON(fiber.Map{"error": "Survey already has responses and can no longer be edited"})
+	}
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(context.Background())
+
+	// Replacing the survey drops the old questions through ON DELETE CASCADE
+	_, err = tx.Exec(context.Background(), `DELETE FROM event_surveys WHERE event_id = $1`, eventID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database delete failed"})
+	}
+
+	var surveyID int
+	err = tx.QueryRow(context.Background(),
+		`INSERT INTO event_surveys (event_id, title) VALUES ($1, $2) RETURNING id`,
+		eventID, body.Title).Scan(&surveyID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+	}
+
+	for i, question := range body.Questions {
+		options := question.Options
+		if question.Kind != models.QuestionMultipleChoice || options == nil {
+			options = []string{}
+		}
+		_, err = tx.Exec(context.Background(),
+			`INSERT INTO event_survey_questions (survey_id, position, kind, prompt, options, required)
+			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			surveyID, i, question.Kind, question.Prompt, options, question.Required)
+		if err != nil {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Survey saved successfully", "survey_id": surveyID})
+}
+
+// GET /api/events/:id/survey
+func GetEventSurvey(c *fiber.Ctx) error {
+	/*
+		Gets the feedback survey for an event
+		Returns the questions and whether the current user already responded
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized/No JWT found"})
+	}
+
+	survey, err := loadEventSurvey(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "No survey for this event"})
+	}
+
+	db.Pool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM event_survey_responses WHERE survey_id = $1 AND user_id = $2)`,
+		survey.ID, claims.UserID).Scan(&survey.HasResponded)
+
+	return c.JSON(survey)
+}
+
+// POST /api/events/:id/survey
+// Body: { "answers": [{ "question_id": 1, "rating": 5 }, { "question_id": 2, "choice": "Yes" }] }
+func SubmitSurveyResponse(c *fiber.Ctx) error {
+	/*
+		Submits the current user's response to an event's feedback survey
+		Only members registered for the event can respond, once, after the event has ended
+	*/
+	token := utils.GetTokenFromRequest(c)
+	claims, err := utils.VerifyJWT(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized/No JWT found"})
+	}
+
+	eventID := c.Params("id")
+
+	survey, err := loadEventSurvey(eventID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "No survey for this event"})
+	}
+
+	var endDate time.Time
+	var status string
+	var registered bool
+	err = db.Pool.QueryRow(context.Background(),
+		`SELECT e.end_date, e.status, EXISTS(SELECT 1 FROM event_registrations er WHERE er.event_id = e.id AND er.user_id = $2 AND er.status = $3)
+		 FROM events e WHERE e.id = $1`, eventID, claims.UserID, models.RegistrationActive).Scan(&endDate, &status, &registered)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
+	}
+	if status == models.EventCancelled {
+		return c.Status(400).JSON(fiber.Map{"error": "This event was cancelled"})
+	}
+	if !registered {
+		return c.Status(403).JSON(fiber.Map{"error": "Only registered attendees can submit feedback"})
+	}
+	if time.Now().Before(endDate) {
+		return c.Status(400).JSON(fiber.Map{"error": "Feedback opens once the event has ended"})
+	}
+
+	var body struct {
+		Answers []models.SurveyAnswer `json:"answers"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
+	}
+
+	// Validate answers against the survey's questions
+	questions := map[int]models.SurveyQuestion{}
+	for _, question := range survey.Questions {
+		questions[question.ID] = question
+	}
+	answered := map[int]bool{}
+	for _, answer := range body.Answers {
+		question, ok := questions[answer.QuestionID]
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Question %d is not part of this survey", answer.QuestionID)})
+		}
+		if answered[answer.QuestionID] {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Question %d answered more than once", answer.QuestionID)})
+		}
+		switch question.Kind {
+		case models.QuestionRating:
+			if answer.Rating < 1 || answer.Rating > 5 {
+				return c.Status(400).JSON(fiber.Map{"error": "Ratings must be between 1 and 5"})
+			}
+		case models.QuestionMultipleChoice:
+			if !slices.Contains(question.Options, answer.Choice) {
+				return c.Status(400).JSON(fiber.Map{"error": "Invalid choice for question: " + question.Prompt})
+			}
+		case models.QuestionText:
+			if strings.TrimSpace(answer.Text) == "" {
+				continue
+			}
+		}
+		answered[answer.QuestionID] = true
+	}
+	for _, question := range survey.Questions {
+		if question.Required && !answered[question.ID] {
+			return c.Status(400).JSON(fiber.Map{"error": "Missing answer for required question: " + question.Prompt})
+		}
+	}
+
+	tx, err := db.Pool.Begin(context.Background())
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+	defer tx.Rollback(context.Background())
+
+	var responseID int
+	err = tx.QueryRow(context.Background(),
+		`INSERT INTO event_survey_responses (survey_id, user_id) VALUES ($1, $2) RETURNING id`,
+		survey.ID, claims.UserID).Scan(&responseID)
+	// 23505 is a unique violation, members get one response per survey
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return c.Status(400).JSON(fiber.Map{"error": "You have already submitted feedback for this event"})
+	}
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+	}
+
+	for _, answer := range body.Answers {
+		if !answered[answer.QuestionID] {
+			continue
+		}
+		var rating *int
+		var choice, text *string
+		switch questions[answer.QuestionID].Kind {
+		case models.QuestionRating:
+			rating = &answer.Rating
+		case models.QuestionMultipleChoice:
+			choice = &answer.Choice
+		case models.QuestionText:
+			text = &answer.Text
+		}
+		_, err = tx.Exec(context.Background(),
+			`INSERT INTO event_survey_answers (response_id, question_id, rating, choice, text_value)
+			 VALUES ($1, $2, $3, $4, $5)`,
+			responseID, answer.QuestionID, rating, choice, text)
+		if err != nil {
+			log.Println("Internal DB Error: ", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Database insert failed"})
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database transaction failed"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Thanks for your feedback!"})
+}
+
+// GET /api/admin/events/:id/survey/results (ADMIN ONLY)
+// Query: ?format=csv to download raw responses
+func GetSurveyResults(c *fiber.Ctx) error {
+	/*
+		Aggregates the responses to an event's feedback survey
+		Returns the average and distribution of ratings, multiple choice counts and free-text answers
+	*/
+	survey, err := loadEventSurvey(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "No survey for this event"})
+	}
+
+	rows, err := db.Pool.Query(context.Background(),
+		`SELECT r.id, r.submitted_at, a.question_id, a.rating, a.choice, a.text_value
+		 FROM event_survey_responses r
+		 LEFT JOIN event_survey_answers a ON a.response_id = r.id
+		 WHERE r.survey_id = $1
+		 ORDER BY r.submitted_at, r.id`, survey.ID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	defer rows.Close()
+
+	type responseRow struct {
+		submittedAt time.Time
+		answers     map[int]string
+	}
+	responseIDs := []int{}
+	responses := map[int]*responseRow{}
+
+	results := map[int]*models.SurveyQuestionResult{}
+	for _, question := range survey.Questions {
+		results[question.ID] = &models.SurveyQuestionResult{
+			QuestionID:   question.ID,
+			Kind:         question.Kind,
+			Prompt:       question.Prompt,
+			Distribution: map[string]int{},
+		}
+	}
+
+	for rows.Next() {
+		var responseID int
+		var questionID *int
+		var submittedAt time.Time
+		var rating *int
+		var choice, text *string
+		if err := rows.Scan(&responseID, &submittedAt, &questionID, &rating, &choice, &text); err != nil {
+			log.Println("Scanner Error: ", err)
+			continue
+		}
+
+		if _, ok := responses[responseID]; !ok {
+			responseIDs = append(responseIDs, responseID)
+			responses[responseID] = &responseRow{submittedAt: submittedAt, answers: map[int]string{}}
+		}
+
+		// Responses that skipped every optional question have no answer rows
+		if questionID == nil {
+			continue
+		}
+		result, ok := results[*questionID]
+		if !ok {
+			continue
+		}
+		result.Answers++
+		switch {
+		case rating != nil:
+			result.AverageRating += float64(*rating)
+			result.Distribution[strconv.Itoa(*rating)]++
+			responses[responseID].answers[*questionID] = strconv.Itoa(*rating)
+		case choice != nil:
+			result.Distribution[*choice]++
+			responses[responseID].answers[*questionID] = *choice
+		case text != nil:
+			result.TextAnswers = append(result.TextAnswers, *text)
+			responses[responseID].answers[*questionID] = *text
+		}
+	}
+
+	if c.Query("format") == "csv" {
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+
+		header := []string{"response_id", "submitted_at"}
+		for _, question := range survey.Questions {
+			header = append(header, question.Prompt)
+		}
+		writer.Write(header)
+
+		for _, responseID := range responseIDs {
+			response := responses[responseID]
+			record := []string{strconv.Itoa(responseID), response.submittedAt.Format(time.RFC3339)}
+			for _, question := range survey.Questions {
+				record = append(record, response.answers[question.ID])
+			}
+			writer.Write(record)
+		}
+		writer.Flush()
+
+		c.Set(fiber.HeaderContentType, "text/csv")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="event_%d_feedback.csv"`, survey.EventID))
+		return c.Send(buf.Bytes())
+	}
+
+	questionResults := []models.SurveyQuestionResult{}
+	for _, question := range survey.Questions {
+		result := results[question.ID]
+		if result.Kind == models.QuestionRating && result.Answers > 0 {
+			result.AverageRating /= float64(result.Answers)
+		}
+		questionResults = append(questionResults, *result)
+	}
+
+	return c.JSON(fiber.Map{
+		"survey_id": survey.ID,
+		"event_id":  survey.EventID,
+		"title":     survey.Title,
+		"responses": len(responseIDs),
+		"questions": questionResults,
+	})
+}
@@ -112,15 +112,8 @@ func uploadToCloudinary(file multipart.File, fileHeader *multipart.FileHeader, u
 		Returns the secure URL of the uploaded image
 	*/
 
-	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
-	apiKey := os.Getenv("CLOUDINARY_API_KEY")
-	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
 	uploadFolder := os.Getenv("CLOUDINARY_UPLOAD_FOLDER")
 
-	if cloudName == "" || apiKey == "" || apiSecret == "" {
-		return "", fmt.Errorf("cloudinary credentials not set in environment variables")
-	}
-
 	// Default folder if not set
 	if uploadFolder == "" {
 		uploadFolder = "CodeForAll-Member-Profile-Photos"
@@ -131,9 +124,27 @@ func uploadToCloudinary(file multipart.File, fileHeader *multipart.FileHeader, u
 		return "", err
 	}
 
-	// Generate timestamp and public_id
-	timestamp := fmt.Sprintf("%d", time.Now().Unix())
 	publicID := fmt.Sprintf("user_%d_%d", userID, time.Now().Unix())
+	return uploadImageToCloudinary(fileBytes, fileHeader.Filename, uploadFolder, publicID)
+}
+
+func uploadImageToCloudinary(fileBytes []byte, filename, uploadFolder, publicID string) (string, error) {
+	/*
+		Uploads image bytes to a Cloudinary folder under the given public_id
+		Uploading to an existing public_id replaces the image
+		Returns the secure URL of the uploaded image
+	*/
+
+	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
+	apiKey := os.Getenv("CLOUDINARY_API_KEY")
+	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
+
+	if cloudName == "" || apiKey == "" || apiSecret == "" {
+		return "", fmt.Errorf("cloudinary credentials not set in environment variables")
+	}
+
+	// Generate timestamp
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
 
 	// Generate signature for Cloudinary
 	signature := generateCloudinarySignature(map[string]string{
@@ -146,7 +157,7 @@ func uploadToCloudinary(file multipart.File, fileHeader *multipart.FileHeader, u
 	writer := multipart.NewWriter(body)
 
 	// Add file field
-	part, err := writer.CreateFormFile("file", fileHeader.Filename)
+	part, err := writer.CreateFormFile("file", filename)
 	if err != nil {
 		return "", err
 	}
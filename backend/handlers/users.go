@@ -2,13 +2,12 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
 	"log"
-	"net/http"
 	"time"
 
 	"github.com/KerlynD/CFA_Member_Profile/backend/db"
 	"github.com/KerlynD/CFA_Member_Profile/backend/models"
+	"github.com/KerlynD/CFA_Member_Profile/backend/secrets"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -16,6 +15,7 @@ import (
 func GetUsers(c *fiber.Ctx) error {
 	/*
 		Gets all users from the database with their schools from education history
+		Optional school_id or system (e.g. CUNY) query parameters to only list members of those schools
 		Returns a JSON array of all users
 	*/
 
@@ -24,15 +24,21 @@ func GetUsers(c *fiber.Ctx) error {
 		SELECT DISTINCT u.id, u.google_id, u.name, u.email, u.picture, u.headline, u.location,
 			COALESCE(
 				NULLIF(u.school, ''), 
-				(SELECT STRING_AGG(DISTINCT eh.school_name, ', ' ORDER BY eh.school_name) 
-				 FROM education_history eh WHERE eh.user_id = u.id)
+				(SELECT STRING_AGG(DISTINCT COALESCE(s.name, eh.school_name), ', ' ORDER BY COALESCE(s.name, eh.school_name))
+				 FROM education_history eh LEFT JOIN schools s ON s.id = eh.school_id
+				 WHERE eh.user_id = u.id)
 			) as schools,
-			(SELECT STRING_AGG(DISTINCT wh.company, ', ' ORDER BY wh.company) 
-			 FROM work_history wh WHERE wh.user_id = u.id) as companies
+			(SELECT STRING_AGG(DISTINCT COALESCE(c.name, wh.company), ', ' ORDER BY COALESCE(c.name, wh.company))
+			 FROM work_history wh LEFT JOIN companies c ON c.id = wh.company_id
+			 WHERE wh.user_id = u.id) as companies
 		FROM users u
 		LEFT JOIN education_history eh ON u.id = eh.user_id
 		LEFT JOIN work_history wh ON u.id = wh.user_id
-		ORDER BY u.name ASC`)
+		WHERE ($1 = 0 OR EXISTS (SELECT 1 FROM education_history f WHERE f.user_id = u.id AND f.school_id = $1))
+		  AND ($2 = '' OR EXISTS (
+			SELECT 1 FROM education_history f JOIN schools fs ON fs.id = f.school_id
+			WHERE f.user_id = u.id AND LOWER(fs.system) = LOWER($2)))
+		ORDER BY u.name ASC`, c.QueryInt("school_id"), c.Query("system"))
 	if err != nil {
 		log.Println("DB Error: ", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
@@ -70,11 +76,13 @@ func GetUserProfile(c *fiber.Ctx) error {
 		SELECT u.id, u.google_id, u.name, u.email, u.picture, u.headline, u.location, u.is_admin,
 			COALESCE(
 				NULLIF(u.school, ''), 
-				(SELECT STRING_AGG(DISTINCT eh.school_name, ', ' ORDER BY eh.school_name) 
-				 FROM education_history eh WHERE eh.user_id = u.id)
+				(SELECT STRING_AGG(DISTINCT COALESCE(s.name, eh.school_name), ', ' ORDER BY COALESCE(s.name, eh.school_name))
+				 FROM education_history eh LEFT JOIN schools s ON s.id = eh.school_id
+				 WHERE eh.user_id = u.id)
 			) as schools,
-			(SELECT STRING_AGG(DISTINCT wh.company, ', ' ORDER BY wh.company) 
-			 FROM work_history wh WHERE wh.user_id = u.id) as companies
+			(SELECT STRING_AGG(DISTINCT COALESCE(c.name, wh.company), ', ' ORDER BY COALESCE(c.name, wh.company))
+			 FROM work_history wh LEFT JOIN companies c ON c.id = wh.company_id
+			 WHERE wh.user_id = u.id) as companies
 		FROM users u
 		WHERE u.id = $1`, id)
 
@@ -104,7 +112,9 @@ func GetUserEducation(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	rows, err := db.Pool.Query(context.Background(),
-		`SELECT id, user_id, school_name, school_logo_url, degree, field_of_study, start_date, end_date, location, description, created_at 
+		`SELECT id, user_id, school_id, school_name, school_logo_url,
+			COALESCE((SELECT s.domain FROM schools s WHERE s.id = education_history.school_id), ''),
+			degree, field_of_study, start_date, end_date, location, description, created_at 
 		FROM education_history WHERE user_id = $1 ORDER BY start_date DESC`, id)
 
 	if err != nil {
@@ -116,9 +126,10 @@ func GetUserEducation(c *fiber.Ctx) error {
 	educationHistory := []models.EducationHistory{}
 	for rows.Next() {
 		var education models.EducationHistory
-		rows.Scan(&education.ID, &education.UserID, &education.SchoolName, &education.SchoolLogoURL,
+		rows.Scan(&education.ID, &education.UserID, &education.SchoolID, &education.SchoolName, &education.SchoolLogoURL, &education.SchoolDomain,
 			&education.Degree, &education.FieldOfStudy, &education.StartDate, &education.EndDate,
 			&education.Location, &education.Description, &education.CreatedAt)
+		education.SchoolLogoURL = logoURL(c, education.SchoolLogoURL, education.SchoolDomain, education.SchoolName)
 		educationHistory = append(educationHistory, education)
 	}
 
@@ -134,7 +145,9 @@ func GetUserWork(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	rows, err := db.Pool.Query(context.Background(),
-		`SELECT id, user_id, company, company_logo_url, title, start_date, end_date, location, description, created_at 
+		`SELECT id, user_id, company_id, company, company_logo_url,
+			COALESCE((SELECT c.domain FROM companies c WHERE c.id = work_history.company_id), ''),
+			title, start_date, end_date, location, description, created_at 
 		FROM work_history WHERE user_id = $1 ORDER BY start_date DESC NULLS LAST`, id)
 
 	if err != nil {
@@ -146,9 +159,10 @@ func GetUserWork(c *fiber.Ctx) error {
 	workHistory := []models.WorkHistory{}
 	for rows.Next() {
 		var work models.WorkHistory
-		rows.Scan(&work.ID, &work.UserID, &work.Company, &work.CompanyLogoURL,
+		rows.Scan(&work.ID, &work.UserID, &work.CompanyID, &work.Company, &work.CompanyLogoURL, &work.CompanyDomain,
 			&work.Title, &work.StartDate, &work.EndDate,
 			&work.Location, &work.Description, &work.CreatedAt)
+		work.CompanyLogoURL = logoURL(c, work.CompanyLogoURL, work.CompanyDomain, work.Company)
 		workHistory = append(workHistory, work)
 	}
 
@@ -158,15 +172,18 @@ func GetUserWork(c *fiber.Ctx) error {
 // GET /api/users/:id/events
 func GetUserEvents(c *fiber.Ctx) error {
 	/*
-		Gets events attended by a specific user
+		Gets events attended and hosted by a specific user
 	*/
 
 	id := c.Params("id")
 
-	// Get count of events attended
+	// Get count of events attended, drafts and cancelled events count for neither the count nor the list
 	var eventsCount int
 	err := db.Pool.QueryRow(context.Background(),
-		`SELECT COUNT(*) FROM event_registrations WHERE user_id = $1`, id).Scan(&eventsCount)
+		`SELECT COUNT(*) FROM event_registrations er
+		 JOIN events e ON e.id = er.event_id
+		 WHERE er.user_id = $1 AND e.status NOT IN ($2, $3)`,
+		id, models.EventDraft, models.EventCancelled).Scan(&eventsCount)
 
 	if err != nil {
 		log.Println("DB Error: ", err)
@@ -178,8 +195,8 @@ func GetUserEvents(c *fiber.Ctx) error {
 		`SELECT e.id, e.title, e.description, e.date, e.end_date, e.room, e.external_link, e.recording_url
 		FROM events e
 		JOIN event_registrations er ON e.id = er.event_id
-		WHERE er.user_id = $1
-		ORDER BY e.date DESC`, id)
+		WHERE er.user_id = $1 AND e.status NOT IN ($2, $3)
+		ORDER BY e.date DESC`, id, models.EventDraft, models.EventCancelled)
 
 	if err != nil {
 		log.Println("DB Error: ", err)
@@ -195,13 +212,42 @@ func GetUserEvents(c *fiber.Ctx) error {
 		events = append(events, event)
 	}
 
-	return c.JSON(fiber.Map{"count": eventsCount, "events": events})
+	// Get list of events hosted
+	hosted := []models.Event{}
+	hostedRows, err := db.Pool.Query(context.Background(),
+		`SELECT e.id, e.title, e.description, e.date, e.end_date, e.room, e.external_link, e.recording_url
+		FROM events e
+		JOIN event_hosts eh ON e.id = eh.event_id
+		WHERE eh.user_id = $1 AND e.status NOT IN ($2, $3)
+		ORDER BY e.date DESC`, id, models.EventDraft, models.EventCancelled)
+	if err != nil {
+		log.Println("DB Error: ", err)
+	} else {
+		defer hostedRows.Close()
+		for hostedRows.Next() {
+			var event models.Event
+			if err := hostedRows.Scan(&event.ID, &event.Title, &event.Description, &event.Date,
+				&event.EndDate, &event.Room, &event.ExternalLink, &event.RecordingURL); err != nil {
+				log.Println("Scanner Error: ", err)
+				continue
+			}
+			hosted = append(hosted, event)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"count":        eventsCount,
+		"events":       events,
+		"hosted_count": len(hosted),
+		"hosted":       hosted,
+	})
 }
 
 // GET /api/users/:id/github
 func GetUserGithub(c *fiber.Ctx) error {
 	/*
 		Gets GitHub integration data for a specific user
+		Includes the member's synced activity summary
 	*/
 
 	id := c.Params("id")
@@ -228,10 +274,21 @@ func GetUserGithub(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"connected": false})
 	}
 
-	// If we have top repos and access token, fetch detailed repo info
-	var detailedRepos []models.GithubRepo
-	if len(integration.TopRepos) > 0 && accessToken != "" {
-		detailedRepos = fetchDetailedRepoInfo(integration.TopRepos, accessToken)
+	// Repo details come from the repo cache, GitHub is only called for repos never fetched before
+	detailedRepos := []models.GithubRepo{}
+	if len(integration.TopRepos) > 0 {
+		accessToken, err = secrets.Decrypt(accessToken)
+		if err != nil {
+			log.Printf("Failed to decrypt GitHub token for user %d: %v", integration.UserID, err)
+			accessToken = ""
+		}
+		detailedRepos = pinnedRepos(integration.UserID, integration.TopRepos, accessToken)
+	}
+
+	// Activity summary synced in the background, nil until the first sync
+	stats, err := loadGithubStats(integration.UserID)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -240,6 +297,7 @@ func GetUserGithub(c *fiber.Ctx) error {
 		"profile_url":    integration.ProfileURL,
 		"top_repos":      integration.TopRepos,
 		"detailed_repos": detailedRepos,
+		"stats":          stats,
 	})
 }
 
@@ -320,40 +378,4 @@ func UpdateUser(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "User updated successfully"})
 }
 
-// Helper function to fetch detailed repository information
-func fetchDetailedRepoInfo(repoNames []string, accessToken string) []models.GithubRepo {
-	var detailedRepos []models.GithubRepo
-
-	for _, repoName := range repoNames {
-		// Fetch repo details from GitHub API
-		req, err := http.NewRequest("GET", "https://api.github.com/repos/"+repoName, nil)
-		if err != nil {
-			continue
-		}
-
-		req.Header.Set("Authorization", "Bearer "+accessToken)
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			continue
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != 200 {
-			continue
-		}
-
-		var repo models.GithubRepo
-		if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
-			continue
-		}
-
-		detailedRepos = append(detailedRepos, repo)
-	}
-
-	return detailedRepos
-}
-
 // DELETE /api/users/:id // TODO: Implement
@@ -34,7 +34,9 @@ func GetWorkHistory(c *fiber.Ctx) error {
 
 	// Query the database for all work history
 	rows, err := db.Pool.Query(context.Background(),
-		`SELECT id, user_id, company, company_logo_url, title, start_date, end_date, location, description, created_at 
+		`SELECT id, user_id, company_id, company, company_logo_url,
+			COALESCE((SELECT c.domain FROM companies c WHERE c.id = work_history.company_id), ''),
+			title, start_date, end_date, location, description, created_at 
 		FROM work_history WHERE user_id = $1 
 		ORDER BY created_at DESC`,
 		claims.UserID)
@@ -49,10 +51,11 @@ func GetWorkHistory(c *fiber.Ctx) error {
 	history := []models.WorkHistory{}
 	for rows.Next() {
 		var workHistory models.WorkHistory
-		rows.Scan(&workHistory.ID, &workHistory.UserID, &workHistory.Company,
-			&workHistory.CompanyLogoURL, &workHistory.Title, &workHistory.StartDate,
+		rows.Scan(&workHistory.ID, &workHistory.UserID, &workHistory.CompanyID, &workHistory.Company,
+			&workHistory.CompanyLogoURL, &workHistory.CompanyDomain, &workHistory.Title, &workHistory.StartDate,
 			&workHistory.EndDate, &workHistory.Location, &workHistory.Description,
 			&workHistory.CreatedAt)
+		workHistory.CompanyLogoURL = logoURL(c, workHistory.CompanyLogoURL, workHistory.CompanyDomain, workHistory.Company)
 		history = append(history, workHistory)
 	}
 	return c.JSON(history)
@@ -85,14 +88,23 @@ func AddWorkHistory(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
 	}
 
-	// Generate company logo URL using Clearbit
-	companyLogo := getCompanyLogoURL(body.Company)
+	if companyKey(body.Company) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Company is required"})
+	}
+
+	// Match the company against the registry so spellings are grouped together
+	company, err := resolveCompany(body.Company)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	companyLogo := company.LogoURL
 
 	// Insert the work history into the database
 	_, err = db.Pool.Exec(context.Background(),
-		`INSERT INTO work_history (user_id, company, company_logo_url, title, start_date, end_date, location, description)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		claims.UserID, body.Company, companyLogo, body.Title, body.StartDate, body.EndDate,
+		`INSERT INTO work_history (user_id, company_id, company, company_logo_url, title, start_date, end_date, location, description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		claims.UserID, company.ID, company.Name, companyLogo, body.Title, body.StartDate, body.EndDate,
 		body.Location, body.Description)
 
 	if err != nil {
@@ -133,15 +145,24 @@ func UpdateWorkHistory(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body/JSON"})
 	}
 
-	// Generate company logo URL using logo.dev
-	companyLogo := getCompanyLogoURL(body.Company)
+	if companyKey(body.Company) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Company is required"})
+	}
+
+	// Match the company against the registry so spellings are grouped together
+	company, err := resolveCompany(body.Company)
+	if err != nil {
+		log.Println("Internal DB Error: ", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Database query failed"})
+	}
+	companyLogo := company.LogoURL
 
 	// Update the work history in the database
 	result, err := db.Pool.Exec(context.Background(),
 		`UPDATE work_history 
-		SET company=$1, company_logo_url=$2, title=$3, start_date=$4, end_date=$5, location=$6, description=$7
-		WHERE id=$8 AND user_id=$9`,
-		body.Company, companyLogo, body.Title, body.StartDate, body.EndDate,
+		SET company_id=$1, company=$2, company_logo_url=$3, title=$4, start_date=$5, end_date=$6, location=$7, description=$8
+		WHERE id=$9 AND user_id=$10`,
+		company.ID, company.Name, companyLogo, body.Title, body.StartDate, body.EndDate,
 		body.Location, body.Description, id, claims.UserID)
 
 	if err != nil {
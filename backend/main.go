@@ -5,9 +5,12 @@ import (
 	"os"
 	"strings"
 
+	"github.com/KerlynD/CFA_Member_Profile/backend/currency"
 	"github.com/KerlynD/CFA_Member_Profile/backend/db"
 	"github.com/KerlynD/CFA_Member_Profile/backend/handlers"
+	"github.com/KerlynD/CFA_Member_Profile/backend/notifications"
 	"github.com/KerlynD/CFA_Member_Profile/backend/routes"
+	"github.com/KerlynD/CFA_Member_Profile/backend/secrets"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/joho/godotenv"
@@ -18,15 +21,33 @@ func main() {
 		Main function for the backend
 		Loads environment variables from .env file
 		Connects to the database
+		Applies pending schema migrations
+		Links existing rows to the company and school registries
+		Loads the keys provider tokens are encrypted with
 		Initializes the OAuth configuration
+		Loads the offer exchange rates
+		Starts the background schedulers
 		Starts the Fiber server
 	*/
 	godotenv.Load()
 	db.Connect()
+	db.Migrate()
+	handlers.BackfillNameRegistries()
+	secrets.Init()
 	handlers.InitOAuth()
 	handlers.InitDiscordOAuth()
 	handlers.InitLinkedinOAuth()
 	handlers.InitGithubOAuth()
+	notifications.Init()
+	currency.Init()
+	handlers.StartEventScheduler()
+	handlers.StartNotificationScheduler()
+	handlers.StartGithubSyncScheduler()
+	handlers.StartLeetCodeSyncScheduler()
+	handlers.StartLeetCodeLeaderboardScheduler()
+	handlers.StartDiscordRoleSyncScheduler()
+	handlers.StartDiscordRSVPScheduler()
+	handlers.StartDiscordVerificationScheduler()
 
 	app := fiber.New()
 
@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"context"
 	"strings"
 
+	"github.com/KerlynD/CFA_Member_Profile/backend/db"
 	"github.com/KerlynD/CFA_Member_Profile/backend/utils"
 	"github.com/gofiber/fiber/v2"
 )
@@ -58,3 +60,28 @@ func RequireAdmin(c *fiber.Ctx) error {
 	}
 	return c.Next()
 }
+
+// Ensure the user is an admin or a host of the event in the :id route parameter
+func RequireEventHost(c *fiber.Ctx) error {
+	/*
+		Require Event Host
+		Admins always pass, other users need a row in event_hosts for the event
+		Returns a 403 Forbidden if the user does not host the event
+	*/
+	if isAdmin, _ := c.Locals("is_admin").(bool); isAdmin {
+		return c.Next()
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+
+	var isHost bool
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM event_hosts WHERE event_id = $1 AND user_id = $2)`,
+		c.Params("id"), userID).Scan(&isHost)
+	if err != nil || !isHost {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Forbidden/Not an event host",
+		})
+	}
+	return c.Next()
+}
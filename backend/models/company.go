@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Canonical company that offers and work history point to
+type Company struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Domain     string    `json:"domain"`   // e.g. "capitalone.com", used for the logo when LogoURL is empty
+	LogoURL    string    `json:"logo_url"` // Overrides the domain logo
+	Aliases    []string  `json:"aliases"`  // Alternate spellings that resolve to this company
+	OfferCount int       `json:"offer_count"`
+	WorkCount  int       `json:"work_count"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Two companies that are probably the same, suggested for merging
+type CompanyMatch struct {
+	Company    Company `json:"company"`
+	Duplicate  Company `json:"duplicate"`
+	Similarity float64 `json:"similarity"` // 0-1
+}
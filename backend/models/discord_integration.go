@@ -11,4 +11,5 @@ type DiscordIntegration struct {
 	AvatarURL    string    `json:"avatar_url"`
 	Verified     bool      `json:"verified"`
 	JoinedAt     time.Time `json:"joined_at"`
+	LastVerifiedAt *time.Time `json:"last_verified_at"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Platform attributes a Discord role can be mapped to
+const (
+	RoleAttributeVerified     = "verified"      // Linked and in the server
+	RoleAttributeSchool       = "school"        // Value is a school ID
+	RoleAttributeSchoolSystem = "school_system" // Value is "CUNY" or "SUNY"
+	RoleAttributeAlumni       = "alumni"        // Every education entry has ended
+	RoleAttributeEvent        = "event"         // Value is an event ID, for registered (not waitlisted) members
+)
+
+type DiscordRoleMapping struct {
+	ID        int       `json:"id"`
+	Attribute string    `json:"attribute"`
+	Value     string    `json:"value"`
+	Label     string    `json:"label"` // School or event name, filled in for display
+	RoleID    string    `json:"role_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// A role in the Discord server, listed so admins can pick one when mapping
+type DiscordRole struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Color    int    `json:"color"`
+	Position int    `json:"position"`
+	Managed  bool   `json:"managed"` // Owned by an integration, can't be assigned by the bot
+}
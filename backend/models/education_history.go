@@ -5,8 +5,10 @@ import "time"
 type EducationHistory struct {
 	ID            int       `json:"id"`
 	UserID        int       `json:"user_id"`
+	SchoolID      *int      `json:"school_id"`
 	SchoolName    string    `json:"school_name"`
 	SchoolLogoURL string    `json:"school_logo_url"`
+	SchoolDomain  string    `json:"school_domain"`
 	Degree        string    `json:"degree"`
 	FieldOfStudy  string    `json:"field_of_study"`
 	StartDate     string    `json:"start_date"`
@@ -2,21 +2,49 @@ package models
 
 import "time"
 
+// Event lifecycle statuses
+const (
+	EventDraft     = "draft"     // Only visible to admins
+	EventPublished = "published" // Public and open for registration
+	EventCancelled = "cancelled" // Kept for history, registrations are marked cancelled
+	EventCompleted = "completed" // Set by the scheduler once the event has ended
+)
+
+// Registration statuses
+const (
+	RegistrationActive     = "registered"
+	RegistrationWaitlisted = "waitlisted"
+	RegistrationCancelled  = "event_cancelled"
+)
+
+// Where a registration came from
+const (
+	RegistrationSourcePlatform = "platform"
+	RegistrationSourceDiscord  = "discord" // Marked "interested" on the Discord scheduled event
+)
+
 type Event struct {
-	ID           int       `json:"id"`
-	Title        string    `json:"title"`
-	Description  string    `json:"description"`
-	Date         time.Time `json:"date"`
-	EndDate      time.Time `json:"end_date"`
-	Room         string    `json:"room"`
-	ExternalLink string    `json:"external_link"`
-	Attendees    int       `json:"attendees"`
-	RecordingURL string    `json:"recording_url"`
-	IsRegistered bool      `json:"is_registered"` // Will be set per user
+	ID           int        `json:"id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Date         time.Time  `json:"date"`
+	EndDate      time.Time  `json:"end_date"`
+	Room         string     `json:"room"`
+	ExternalLink string     `json:"external_link"`
+	Attendees    int        `json:"attendees"`
+	RecordingURL string     `json:"recording_url"`
+	Capacity     int        `json:"capacity"` // 0 means unlimited
+	Status       string     `json:"status"`
+	PublishAt    *time.Time `json:"publish_at"` // Drafts are published automatically at this time
+	CancelledAt  *time.Time `json:"cancelled_at"`
+	CancelReason string     `json:"cancel_reason"`
+	IsRegistered bool       `json:"is_registered"` // Will be set per user
+	IsWaitlisted bool       `json:"is_waitlisted"` // Will be set per user
 }
 
 type EventRegistration struct {
-	ID      int `json:"id"`
-	EventID int `json:"event_id"`
-	UserID  int `json:"user_id"`
+	ID      int    `json:"id"`
+	EventID int    `json:"event_id"`
+	UserID  int    `json:"user_id"`
+	Status  string `json:"status"`
 }
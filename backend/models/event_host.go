@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Host roles
+const (
+	HostRoleHost        = "host"
+	HostRoleCoOrganizer = "co-organizer"
+)
+
+type EventHost struct {
+	EventID int       `json:"event_id"`
+	UserID  int       `json:"user_id"`
+	Name    string    `json:"name"`
+	Picture string    `json:"picture"`
+	Role    string    `json:"role"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// External speakers don't need a member account
+type EventSpeaker struct {
+	ID       int      `json:"id"`
+	EventID  int      `json:"event_id"`
+	Name     string   `json:"name"`
+	Bio      string   `json:"bio"`
+	PhotoURL string   `json:"photo_url"`
+	Links    []string `json:"links"`
+	Position int      `json:"position"`
+}
@@ -25,3 +25,32 @@ type GithubRepo struct {
 	HTMLURL     string `json:"html_url"`
 	Private     bool   `json:"private"`
 }
+
+// Share of a member's public code in one language
+type GithubLanguage struct {
+	Name    string  `json:"name"`
+	Color   string  `json:"color"`
+	Bytes   int     `json:"bytes"`
+	Percent float64 `json:"percent"`
+}
+
+type GithubPullRequest struct {
+	Title     string     `json:"title"`
+	URL       string     `json:"url"`
+	Repo      string     `json:"repo"`  // owner/repo format
+	State     string     `json:"state"` // "OPEN", "CLOSED" or "MERGED"
+	CreatedAt time.Time  `json:"created_at"`
+	MergedAt  *time.Time `json:"merged_at"`
+}
+
+// Public GitHub activity synced periodically so profiles don't call GitHub on every view
+type GithubStats struct {
+	ContributionsLastYear int                 `json:"contributions_last_year"`
+	Followers             int                 `json:"followers"`
+	Following             int                 `json:"following"`
+	PublicRepos           int                 `json:"public_repos"`
+	Languages             []GithubLanguage    `json:"languages"`
+	RecentPullRequests    []GithubPullRequest `json:"recent_pull_requests"`
+	SyncedAt              *time.Time          `json:"synced_at"`
+	SyncError             string              `json:"-"` // Raw upstream error, kept off public profiles
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Token health values reported in the integrations overview
+const (
+	TokenNotStored     = "not_stored"     // Provider doesn't keep a token for this member
+	TokenOK            = "ok"             // Token decrypts with the active key
+	TokenNeedsRotation = "needs_rotation" // Token is plaintext or sealed with an old key
+	TokenUnreadable    = "unreadable"     // Token can't be decrypted with any configured key
+	TokenRejected      = "rejected"       // Provider rejected the token on the last sync
+)
+
+// Uniform status every provider reports in the integrations overview
+type IntegrationStatus struct {
+	Provider    string     `json:"provider"`
+	Name        string     `json:"name"`
+	Linked      bool       `json:"linked"`
+	Handle      string     `json:"handle,omitempty"`
+	AvatarURL   string     `json:"avatar_url,omitempty"`
+	ProfileURL  string     `json:"profile_url,omitempty"`
+	LinkedAt    *time.Time `json:"linked_at"`
+	Verified    *bool      `json:"verified"` // nil when the provider has no verification step
+	TokenHealth string     `json:"token_health"`
+	Error       string     `json:"error,omitempty"`
+}
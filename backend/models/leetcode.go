@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Structures used by LeetCode leaderboard features
 
 type Submission struct {
@@ -37,17 +39,66 @@ type LeaderboardCard struct {
 	LocalRanking     int      `json:"local_ranking,omitempty"`
 	Avatar           string   `json:"avatar,omitempty"`
 	Problems         Problems `json:"problems,omitempty"`
+	// Only set for accounts linked directly and synced from LeetCode
+	EasySolved    int        `json:"easy_solved,omitempty"`
+	MediumSolved  int        `json:"medium_solved,omitempty"`
+	HardSolved    int        `json:"hard_solved,omitempty"`
+	ContestRating float64    `json:"contest_rating,omitempty"`
+	SyncedAt      *time.Time `json:"synced_at,omitempty"`
+}
+
+// LeetCode account a member linked by username, synced from LeetCode's public GraphQL API
+type LeetCodeIntegration struct {
+	UserID            int          `json:"user_id"`
+	Username          string       `json:"username"`
+	AvatarURL         string       `json:"avatar_url"`
+	Ranking           int          `json:"ranking"`
+	EasySolved        int          `json:"easy_solved"`
+	MediumSolved      int          `json:"medium_solved"`
+	HardSolved        int          `json:"hard_solved"`
+	TotalSolved       int          `json:"total_solved"`
+	ContestRating     float64      `json:"contest_rating"`
+	ContestsAttended  int          `json:"contests_attended"`
+	RecentSubmissions []Submission `json:"recent_submissions"` // Recent accepted submissions only
+	LinkedAt          time.Time    `json:"linked_at"`
+	SyncedAt          *time.Time   `json:"synced_at"`
+	SyncError         string       `json:"sync_error,omitempty"`
 }
 
-type Top10Row struct {
-	DiscordUsername string  `json:"discord_username"`
-	Username        string  `json:"username"`
-	Points          float64 `json:"points"`
+// Solved counts and contest rating on one day, for charting progress
+type LeetCodeSnapshot struct {
+	Date          string  `json:"date"` // YYYY-MM-DD
+	EasySolved    int     `json:"easy_solved"`
+	MediumSolved  int     `json:"medium_solved"`
+	HardSolved    int     `json:"hard_solved"`
+	TotalSolved   int     `json:"total_solved"`
+	ContestRating float64 `json:"contest_rating"`
 }
 
-type AllTimeRow struct {
-	DiscordUsername  string `json:"discord_username"`
+// Leaderboard windows and the metrics members can be ranked by
+const (
+	LeaderboardWeekly  = "weekly"
+	LeaderboardMonthly = "monthly"
+	LeaderboardAllTime = "all_time"
+
+	LeaderboardSortPoints   = "points"
+	LeaderboardSortWins     = "wins"
+	LeaderboardSortProblems = "problems"
+)
+
+// One member on the chapter leaderboard, counts cover the requested window only
+type LeaderboardEntry struct {
+	Rank             int    `json:"rank"`
+	UserID           int    `json:"user_id"`
+	Name             string `json:"name"`
+	Picture          string `json:"picture"`
 	LeetCodeUsername string `json:"leetcode_username"`
-	TotalWins        int    `json:"total_wins"`
-	TotalPoints      int    `json:"total_points"`
+	DiscordUsername  string `json:"discord_username,omitempty"`
+	Avatar           string `json:"avatar"`
+	Points           int    `json:"points"`
+	Wins             int    `json:"wins"` // Days the member gained the most points in the chapter
+	ProblemsSolved   int    `json:"problems_solved"`
+	EasySolved       int    `json:"easy_solved"`
+	MediumSolved     int    `json:"medium_solved"`
+	HardSolved       int    `json:"hard_solved"`
 }
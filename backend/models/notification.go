@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Per-user email opt-outs, everything is enabled by default
+type NotificationPreferences struct {
+	UserID        int       `json:"user_id"`
+	Confirmations bool      `json:"confirmations"` // Registration and waitlist confirmations
+	Reminders     bool      `json:"reminders"`     // 24h and 1h before an event
+	Waitlist      bool      `json:"waitlist"`      // Promotions off the waitlist
+	Cancellations bool      `json:"cancellations"`
+	Announcements bool      `json:"announcements"` // Messages from event organizers
+	UpdatedAt     time.Time `json:"updated_at"`
+}
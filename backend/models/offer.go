@@ -2,15 +2,64 @@ package models
 
 import "time"
 
+// Moderation statuses, only approved offers are public
+const (
+	OfferApproved = "approved"
+	OfferPending  = "pending"
+	OfferRejected = "rejected"
+)
+
+// Offer types
+const (
+	OfferInternship = "internship"
+	OfferFullTime   = "full-time"
+)
+
 type Offer struct {
-	ID             int       `json:"id"`
-	UserID         int       `json:"user_id"`
-	Company        string    `json:"company"`
-	CompanyLogoURL string    `json:"company_logo_url"`
-	Role           string    `json:"role"`
-	OfferType      string    `json:"offer_type"` // "internship" or "full-time"
-	HourlyRate     float64   `json:"hourly_rate"`
-	MonthlyRate    float64   `json:"monthly_rate"`
-	Location       string    `json:"location"`
+	ID             int     `json:"id"`
+	UserID         *int    `json:"user_id,omitempty"`        // Only exposed when ShowName is set or to the owner
+	SubmitterName  string  `json:"submitter_name,omitempty"` // Only exposed when ShowName is set
+	ShowName       bool    `json:"show_name"`                // Opt-in, offers are anonymous by default
+	CompanyID      *int    `json:"company_id"`               // Registry entry the company name resolved to
+	Company        string  `json:"company"`
+	CompanyLogoURL string  `json:"company_logo_url"`
+	CompanyDomain  string  `json:"company_domain"` // From the company registry, used to build the logo URL
+	Role           string  `json:"role"`
+	Level          string  `json:"level"`      // e.g. "L3", "SWE II"
+	OfferType      string  `json:"offer_type"` // "internship" or "full-time"
+	Season         string  `json:"season"`     // "Spring", "Summer", "Fall" or "Winter"
+	Year           int     `json:"year"`
+	WorkMode       string  `json:"work_mode"` // "remote", "hybrid" or "onsite"
+	Location       string  `json:"location"`
+	ReturnOffer    bool    `json:"return_offer"`
+	Currency       string  `json:"currency"` // ISO 4217 code the amounts below are in, defaults to USD
+	HourlyRate     float64 `json:"hourly_rate"`
+	MonthlyRate    float64 `json:"monthly_rate"`
+	BaseSalary     float64 `json:"base_salary"` // Annual
+	SigningBonus   float64 `json:"signing_bonus"`
+	Relocation     float64 `json:"relocation"`
+	HousingStipend float64 `json:"housing_stipend"` // Total for the offer, not per month
+	StockAmount    float64 `json:"stock_amount"`    // Total grant value
+	StockVesting   string  `json:"stock_vesting"`   // e.g. "4 years, 1 year cliff"
+	VestingYears   int     `json:"vesting_years"`
+	// First-year total compensation of full-time offers, computed server-side:
+	// annual base + one year of stock + signing, relocation and housing, 0 for internships
+	TotalComp float64 `json:"total_comp"`
+	// Amounts converted to USD with the current exchange rates so offers can be compared
+	HourlyRateUSD  float64   `json:"hourly_rate_usd"`
+	MonthlyRateUSD float64   `json:"monthly_rate_usd"`
+	BaseSalaryUSD  float64   `json:"base_salary_usd"`
+	TotalCompUSD   float64   `json:"total_comp_usd"`
 	CreatedAt      time.Time `json:"created_at"`
+	Status         string    `json:"status,omitempty"`       // Only shown to the owner and admins
+	FlagReasons    []string  `json:"flag_reasons,omitempty"` // Why the offer was held for review
+}
+
+type OfferReport struct {
+	ID         int        `json:"id"`
+	OfferID    int        `json:"offer_id"`
+	UserID     int        `json:"user_id"`
+	Reason     string     `json:"reason"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
 }
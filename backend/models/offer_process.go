@@ -0,0 +1,51 @@
+package models
+
+// Interview round kinds
+const (
+	RoundOnlineAssessment = "oa"
+	RoundPhoneScreen      = "phone_screen"
+	RoundTechnical        = "technical"
+	RoundBehavioral       = "behavioral"
+	RoundSystemDesign     = "system_design"
+	RoundOnsite           = "onsite"
+	RoundOther            = "other"
+)
+
+// Key dates of how an offer was landed, all "YYYY-MM-DD" and optional
+type OfferTimeline struct {
+	AppliedOn     string `json:"applied_on,omitempty"`
+	OAOn          string `json:"oa_on,omitempty"`
+	PhoneScreenOn string `json:"phone_screen_on,omitempty"`
+	OnsiteOn      string `json:"onsite_on,omitempty"`
+	OfferOn       string `json:"offer_on,omitempty"`
+	DecisionOn    string `json:"decision_on,omitempty"`
+	Decision      string `json:"decision,omitempty"` // "accepted", "declined" or "pending"
+}
+
+type InterviewRound struct {
+	ID         int      `json:"id"`
+	Position   int      `json:"position"`
+	Kind       string   `json:"kind"`
+	Difficulty int      `json:"difficulty"` // 1 (easy) - 5 (very hard)
+	Topics     []string `json:"topics"`     // e.g. "graphs", "dynamic programming"
+	Notes      string   `json:"notes"`
+}
+
+type OfferProcess struct {
+	OfferID  int              `json:"offer_id"`
+	Timeline *OfferTimeline   `json:"timeline"`
+	Rounds   []InterviewRound `json:"rounds"`
+}
+
+// Typical interview process at a company, aggregated over every shared process
+type CompanyProcessStats struct {
+	Company string `json:"company"`
+	Count   int    `json:"count"`
+	// Median days between applying and each later stage, only stages with enough data are included
+	MedianDaysToStage map[string]float64 `json:"median_days_to_stage"`
+	// Share of processes (0-1) that included each round kind
+	StageFrequency    map[string]float64 `json:"stage_frequency"`
+	AverageRounds     float64            `json:"average_rounds"`
+	AverageDifficulty float64            `json:"average_difficulty"`
+	TopTopics         []string           `json:"top_topics"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// Distribution of a single compensation figure within a group
+type RateSummary struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	P25    float64 `json:"p25"`
+	Median float64 `json:"median"`
+	P75    float64 `json:"p75"`
+	Max    float64 `json:"max"`
+}
+
+type OfferGroupStats struct {
+	Key     string      `json:"key"`
+	Count   int         `json:"count"`
+	Hourly  RateSummary `json:"hourly_rate"`
+	Monthly RateSummary `json:"monthly_rate"`
+	Total   RateSummary `json:"total_comp"`
+}
+
+type OfferStats struct {
+	MinGroupSize int               `json:"min_group_size"` // Groups with fewer offers are left out
+	TotalOffers  int               `json:"total_offers"`
+	Overall      OfferGroupStats   `json:"overall"`
+	ByCompany    []OfferGroupStats `json:"by_company"`
+	ByRole       []OfferGroupStats `json:"by_role"`
+	ByOfferType  []OfferGroupStats `json:"by_offer_type"`
+	ByLocation   []OfferGroupStats `json:"by_location"`
+	BySeason     []OfferGroupStats `json:"by_season"` // e.g. "Summer 2025"
+	ByYear       []OfferGroupStats `json:"by_year"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// School systems members are grouped by
+const (
+	SchoolSystemCUNY = "CUNY"
+	SchoolSystemSUNY = "SUNY"
+)
+
+// Canonical school that education history points to
+type School struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Domain      string    `json:"domain"`   // e.g. "qc.cuny.edu", used for the logo when LogoURL is empty
+	LogoURL     string    `json:"logo_url"` // Overrides the domain logo
+	Campus      string    `json:"campus"`   // Campus location, e.g. "Queens, NY"
+	System      string    `json:"system"`   // University system like "CUNY" or "SUNY", empty for independent schools
+	Aliases     []string  `json:"aliases"`  // Alternate spellings that resolve to this school
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Question kinds supported by event surveys
+const (
+	QuestionRating         = "rating" // 1-5 scale
+	QuestionMultipleChoice = "multiple_choice"
+	QuestionText           = "text"
+)
+
+type EventSurvey struct {
+	ID           int              `json:"id"`
+	EventID      int              `json:"event_id"`
+	Title        string           `json:"title"`
+	Questions    []SurveyQuestion `json:"questions"`
+	HasResponded bool             `json:"has_responded"` // Will be set per user
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+type SurveyQuestion struct {
+	ID       int      `json:"id"`
+	Position int      `json:"position"`
+	Kind     string   `json:"kind"`
+	Prompt   string   `json:"prompt"`
+	Options  []string `json:"options"` // Only used by multiple_choice questions
+	Required bool     `json:"required"`
+}
+
+type SurveyAnswer struct {
+	QuestionID int    `json:"question_id"`
+	Rating     int    `json:"rating,omitempty"`
+	Choice     string `json:"choice,omitempty"`
+	Text       string `json:"text,omitempty"`
+}
+
+type SurveyQuestionResult struct {
+	QuestionID    int            `json:"question_id"`
+	Kind          string         `json:"kind"`
+	Prompt        string         `json:"prompt"`
+	Answers       int            `json:"answers"`
+	AverageRating float64        `json:"average_rating,omitempty"`
+	Distribution  map[string]int `json:"distribution,omitempty"`
+	TextAnswers   []string       `json:"text_answers,omitempty"`
+}
@@ -5,8 +5,10 @@ import "time"
 type WorkHistory struct {
 	ID              int       `json:"id"`
 	UserID          int       `json:"user_id"`
+	CompanyID       *int      `json:"company_id"`
 	Company         string    `json:"company"`
 	CompanyLogoURL  string    `json:"company_logo_url"`
+	CompanyDomain   string    `json:"company_domain"`
 	Title           string    `json:"title"`
 	StartDate       string    `json:"start_date"`
 	EndDate         string    `json:"end_date"`
@@ -0,0 +1,126 @@
+// Package notifications delivers emails to members through a pluggable sender
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A single email to one recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// EmailSender is implemented by every delivery backend
+type EmailSender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogSender writes emails to the server log instead of delivering them
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, msg Message) error {
+	log.Printf("[notification] to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileSender appends emails to a local file, useful for testing templates locally
+type FileSender struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n----\n\n",
+		time.Now().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	return err
+}
+
+// SMTPSender delivers emails through an SMTP server using PLAIN auth
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	// Strip newlines so user-provided subjects can't inject headers
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Subject)
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		s.From, msg.To, subject, msg.Body)
+
+	return smtp.SendMail(s.Host+":"+s.Port, auth, s.From, []string{msg.To}, []byte(body))
+}
+
+var (
+	mu            sync.RWMutex
+	defaultSender EmailSender = LogSender{}
+)
+
+func Init() {
+	/*
+		Picks the email sender from NOTIFICATION_SENDER ("smtp", "file" or "log")
+		Defaults to logging so local development never sends real emails
+	*/
+	switch os.Getenv("NOTIFICATION_SENDER") {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		SetSender(&SMTPSender{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		})
+	case "file":
+		path := os.Getenv("NOTIFICATION_FILE")
+		if path == "" {
+			path = "notifications.log"
+		}
+		SetSender(&FileSender{Path: path})
+	default:
+		SetSender(LogSender{})
+	}
+}
+
+// SetSender replaces the sender used by Send
+func SetSender(sender EmailSender) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultSender = sender
+}
+
+// Send delivers an email through the configured sender
+func Send(ctx context.Context, msg Message) error {
+	mu.RLock()
+	sender := defaultSender
+	mu.RUnlock()
+	return sender.Send(ctx, msg)
+}
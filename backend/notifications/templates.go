@@ -0,0 +1,106 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// Notification kinds, each with its own template and opt-out setting
+const (
+	KindConfirmation      = "confirmation"
+	KindReminder24h       = "reminder_24h"
+	KindReminder1h        = "reminder_1h"
+	KindWaitlisted        = "waitlisted"
+	KindWaitlistPromotion = "waitlist_promotion"
+	KindCancellation      = "cancellation"
+	KindAnnouncement      = "announcement"
+)
+
+// Data available to every template
+type EventData struct {
+	Name         string
+	EventTitle   string
+	EventDate    time.Time
+	Room         string
+	ExternalLink string
+	Reason       string // Only set for cancellations
+}
+
+type emailTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+var templates = map[string]emailTemplate{
+	KindConfirmation: newTemplate(
+		`You're registered for {{.EventTitle}}`,
+		`Hi {{.Name}},
+
+You're registered for {{.EventTitle}} on {{.EventDate.Format "Monday, Jan 2 at 3:04 PM"}}.
+{{if .Room}}Room: {{.Room}}
+{{end}}{{if .ExternalLink}}Link: {{.ExternalLink}}
+{{end}}
+See you there!`),
+	KindReminder24h: newTemplate(
+		`Tomorrow: {{.EventTitle}}`,
+		`Hi {{.Name}},
+
+Just a reminder that {{.EventTitle}} starts tomorrow, {{.EventDate.Format "Monday, Jan 2 at 3:04 PM"}}.
+{{if .Room}}Room: {{.Room}}
+{{end}}{{if .ExternalLink}}Link: {{.ExternalLink}}
+{{end}}`),
+	KindReminder1h: newTemplate(
+		`Starting soon: {{.EventTitle}}`,
+		`Hi {{.Name}},
+
+{{.EventTitle}} starts in about an hour.
+{{if .Room}}Room: {{.Room}}
+{{end}}{{if .ExternalLink}}Link: {{.ExternalLink}}
+{{end}}`),
+	KindWaitlisted: newTemplate(
+		`You're on the waitlist for {{.EventTitle}}`,
+		`Hi {{.Name}},
+
+{{.EventTitle}} is currently full, so you've been added to the waitlist.
+We'll email you if a spot opens up.`),
+	KindWaitlistPromotion: newTemplate(
+		`A spot opened up for {{.EventTitle}}`,
+		`Hi {{.Name}},
+
+Good news! A spot opened up and you're now registered for {{.EventTitle}} on {{.EventDate.Format "Monday, Jan 2 at 3:04 PM"}}.
+If you can no longer make it, please unregister so someone else can take your spot.`),
+	KindCancellation: newTemplate(
+		`Cancelled: {{.EventTitle}}`,
+		`Hi {{.Name}},
+
+Unfortunately {{.EventTitle}} on {{.EventDate.Format "Monday, Jan 2"}} has been cancelled.
+{{if .Reason}}Reason: {{.Reason}}
+{{end}}
+Sorry for the inconvenience.`),
+}
+
+func newTemplate(subject, body string) emailTemplate {
+	return emailTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.New("body").Parse(body)),
+	}
+}
+
+// Render fills in the template for a notification kind
+func Render(kind string, data EventData) (subject string, body string, err error) {
+	tmpl, ok := templates[kind]
+	if !ok {
+		return "", "", fmt.Errorf("no template for notification kind %q", kind)
+	}
+
+	var subjectBuf, bodyBuf bytes.Buffer
+	if err := tmpl.subject.Execute(&subjectBuf, data); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.body.Execute(&bodyBuf, data); err != nil {
+		return "", "", err
+	}
+	return subjectBuf.String(), bodyBuf.String(), nil
+}
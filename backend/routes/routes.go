@@ -11,17 +11,15 @@ func RegisterRoutes(app *fiber.App) {
 	app.Get("/api/auth/google/login", handlers.GoogleLogin)
 	app.Get("/api/auth/google/callback", handlers.GoogleCallback)
 
-	// LinkedIn Integration (not auth)
-	app.Get("/api/integrations/linkedin/connect", handlers.LinkedInIntegrationLogin)
-	app.Get("/api/integrations/linkedin/callback", handlers.LinkedInIntegrationCallback)
+	// Integrations - providers redirect the browser back here, so these check the JWT themselves
+	app.Get("/api/integrations/:provider/connect", handlers.ConnectIntegration)
+	app.Get("/api/integrations/:provider/callback", handlers.IntegrationCallback)
 
-	// Discord Auth
-	app.Get("/api/auth/discord/login", handlers.DiscordLogin)
-	app.Get("/api/auth/discord/callback", handlers.DiscordCallback)
-
-	// GitHub Auth
-	app.Get("/api/auth/github/login", handlers.GithubLogin)
-	app.Get("/api/auth/github/callback", handlers.GithubCallback)
+	// Older connect routes, their callbacks are the redirect URLs registered with Discord and GitHub
+	app.Get("/api/auth/discord/login", handlers.ConnectIntegrationFor("discord"))
+	app.Get("/api/auth/discord/callback", handlers.IntegrationCallbackFor("discord"))
+	app.Get("/api/auth/github/login", handlers.ConnectIntegrationFor("github"))
+	app.Get("/api/auth/github/callback", handlers.IntegrationCallbackFor("github"))
 
 	// Logout
 	app.Get("/api/logout", handlers.Logout)
@@ -39,10 +37,24 @@ func RegisterRoutes(app *fiber.App) {
 
 	// Offers
 	app.Get("/api/offers", handlers.GetOffers)
+	app.Get("/api/offers/stats", handlers.GetOfferStats)
+	app.Get("/api/offers/currencies", handlers.GetExchangeRates)
+	app.Get("/api/offers/process/stats", handlers.GetCompanyProcessStats)
+	app.Get("/api/offers/:id/process", handlers.GetOfferProcess)
+
+	// Companies
+	app.Get("/api/companies", handlers.SearchCompanies)
+
+	// Schools
+	app.Get("/api/schools", handlers.SearchSchools)
+
+	// Company and school logos
+	app.Get("/api/logos/:domain?", handlers.GetLogo)
 
 	// Events
 	app.Get("/api/events", handlers.GetEvents)
 	app.Get("/api/events/:id/attendees", handlers.GetEventAttendees)
+	app.Get("/api/events/:id/hosts", handlers.GetEventHosts)
 
 	// --- PROTECTED ENDPOINTS ---
 	auth := app.Group("/api", middleware.RequireAuth)
@@ -51,6 +63,19 @@ func RegisterRoutes(app *fiber.App) {
 	auth.Post("/events/:id/register", handlers.RegisterForEvent)
 	auth.Delete("/events/:id/register", handlers.UnregisterFromEvent)
 
+	// Event Hosts - hosts can manage their own events without being global admins
+	auth.Put("/events/:id", middleware.RequireEventHost, handlers.UpdateEvent)
+	auth.Get("/events/:id/registrations", middleware.RequireEventHost, handlers.GetEventRegistrations)
+	auth.Post("/events/:id/checkin/:userId", middleware.RequireEventHost, handlers.CheckInAttendee)
+	auth.Delete("/events/:id/checkin/:userId", middleware.RequireEventHost, handlers.UndoCheckIn)
+	auth.Post("/events/:id/speakers", middleware.RequireEventHost, handlers.AddEventSpeaker)
+	auth.Put("/events/:id/speakers/:speakerId", middleware.RequireEventHost, handlers.UpdateEventSpeaker)
+	auth.Delete("/events/:id/speakers/:speakerId", middleware.RequireEventHost, handlers.DeleteEventSpeaker)
+
+	// Event Feedback Surveys
+	auth.Get("/events/:id/survey", handlers.GetEventSurvey)
+	auth.Post("/events/:id/survey", handlers.SubmitSurveyResponse)
+
 	// Profile - IMPORTANT: Specific routes must come before parameterized routes
 	auth.Get("/me", handlers.GetCurrentUser)
 	auth.Put("/users/me", handlers.UpdateMyProfile)
@@ -59,15 +84,27 @@ func RegisterRoutes(app *fiber.App) {
 	auth.Delete("/users/me/resume", handlers.DeleteResume)
 	auth.Put("/users/:id", handlers.UpdateUser)
 
+	// Notification Preferences
+	auth.Get("/notifications/preferences", handlers.GetNotificationPreferences)
+	auth.Put("/notifications/preferences", handlers.UpdateNotificationPreferences)
+
 	// Integrations
 	auth.Get("/integrations", handlers.GetIntegrationsOverview)
+	auth.Delete("/integrations/:provider", handlers.DisconnectIntegration)
+	auth.Post("/integrations/:provider/disconnect", handlers.DisconnectIntegration)
 	auth.Post("/integrations/discord/verify", handlers.VerifyDiscordMembership)
 
 	// LeetCode Leaderboard lookup (read-only)
 	auth.Get("/leetcode/lookup", handlers.GetLeetCodeStats)
+	auth.Get("/leetcode/leaderboard", handlers.GetLeetCodeLeaderboard)
 
 	// Offers
 	auth.Post("/offers", handlers.AddOffer)
+	auth.Get("/offers/mine", handlers.GetMyOffers)
+	auth.Put("/offers/mine/:id", handlers.UpdateMyOffer)
+	auth.Delete("/offers/mine/:id", handlers.DeleteMyOffer)
+	auth.Put("/offers/mine/:id/process", handlers.SaveOfferProcess)
+	auth.Post("/offers/:id/report", handlers.ReportOffer)
 
 	// Discord Integration
 	auth.Get("/integrations/discord", handlers.GetDiscordIntegration)
@@ -76,10 +113,16 @@ func RegisterRoutes(app *fiber.App) {
 	auth.Get("/integrations/github", handlers.GetGithubIntegration)
 	auth.Get("/integrations/github/repos", handlers.GetGithubRepos)
 	auth.Post("/integrations/github/repos", handlers.SaveTopRepos)
+	auth.Post("/integrations/github/sync", handlers.SyncMyGithubStats)
+
+	// LeetCode Integration - linked by username, synced from LeetCode
+	auth.Get("/integrations/leetcode", handlers.GetLeetCodeIntegration)
+	auth.Put("/integrations/leetcode", handlers.LinkLeetCode)
+	auth.Post("/integrations/leetcode/sync", handlers.SyncMyLeetCodeStats)
+	auth.Get("/integrations/leetcode/history", handlers.GetLeetCodeHistory)
 
 	// LinkedIn Integration
 	auth.Get("/integrations/linkedin", handlers.GetLinkedInIntegration)
-	auth.Delete("/integrations/linkedin", handlers.DisconnectLinkedIn)
 	auth.Put("/integrations/linkedin/url", handlers.UpdateLinkedInProfileURL)
 
 	// Location search (GeoDB)
@@ -102,4 +145,34 @@ func RegisterRoutes(app *fiber.App) {
 	admin.Post("/events", handlers.AddEvent)
 	admin.Put("/events/:id", handlers.UpdateEvent)
 	admin.Delete("/events/:id", handlers.DeleteEvent)
+	admin.Post("/events/:id/publish", handlers.PublishEvent)
+	admin.Post("/events/:id/cancel", handlers.CancelEvent)
+	admin.Get("/events/:id/attendees/export", handlers.ExportEventAttendees)
+	admin.Post("/events/:id/announce", handlers.AnnounceToEvent)
+	admin.Post("/events/:id/hosts", handlers.AddEventHost)
+	admin.Delete("/events/:id/hosts/:userId", handlers.RemoveEventHost)
+
+	admin.Get("/offers/moderation", handlers.GetOfferModerationQueue)
+	admin.Post("/offers/:id/approve", handlers.ApproveOffer)
+	admin.Post("/offers/:id/reject", handlers.RejectOffer)
+	admin.Delete("/offers/:id", handlers.AdminDeleteOffer)
+	admin.Get("/companies/duplicates", handlers.GetCompanyDuplicates)
+	admin.Post("/companies", handlers.CreateCompany)
+	admin.Put("/companies/:id", handlers.UpdateCompany)
+	admin.Post("/companies/:id/aliases", handlers.AddCompanyAlias)
+	admin.Delete("/companies/:id/aliases/:alias", handlers.DeleteCompanyAlias)
+	admin.Post("/companies/:id/merge", handlers.MergeCompanies)
+	admin.Post("/schools", handlers.CreateSchool)
+	admin.Put("/schools/:id", handlers.UpdateSchool)
+	admin.Post("/schools/:id/aliases", handlers.AddSchoolAlias)
+	admin.Delete("/schools/:id/aliases/:alias", handlers.DeleteSchoolAlias)
+	admin.Post("/schools/:id/merge", handlers.MergeSchools)
+	admin.Get("/discord/roles", handlers.GetDiscordRoleMappings)
+	admin.Post("/discord/roles", handlers.SaveDiscordRoleMapping)
+	admin.Delete("/discord/roles/:id", handlers.DeleteDiscordRoleMapping)
+	admin.Post("/discord/roles/sync", handlers.SyncDiscordRoles)
+	admin.Put("/exchange-rates", handlers.UpdateExchangeRates)
+	admin.Post("/exchange-rates/refresh", handlers.RefreshExchangeRates)
+	admin.Put("/events/:id/survey", handlers.SaveEventSurvey)
+	admin.Get("/events/:id/survey/results", handlers.GetSurveyResults)
 }
@@ -0,0 +1,232 @@
+// Package secrets encrypts third-party OAuth tokens before they are stored in the database
+//
+// Tokens are envelope encrypted: every token gets its own random data key, the token is
+// sealed with the data key and the data key is sealed with a key-encryption key from config.
+// The stored value carries the ID of the key-encryption key so keys can be rotated:
+//
+//	v1:<key id>:<base64 sealed data key>:<base64 sealed token>
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+const version = "v1"
+
+var (
+	ErrNoKey      = errors.New("token encryption key is not configured")
+	ErrUnknownKey = errors.New("token was encrypted with an unknown key")
+	ErrMalformed  = errors.New("malformed encrypted token")
+)
+
+var (
+	mu       sync.RWMutex
+	keys     = map[string][]byte{}
+	activeID string
+)
+
+func Init() {
+	/*
+		Loads the key-encryption keys from TOKEN_ENCRYPTION_KEYS ("id:base64key,id:base64key")
+		TOKEN_ENCRYPTION_KEY_ID picks the key new tokens are sealed with (default the first one)
+		Older keys stay listed so tokens sealed with them can still be opened until rotated
+		Keys must be 32 bytes (AES-256), e.g. `openssl rand -base64 32`
+	*/
+	if err := Load(os.Getenv("TOKEN_ENCRYPTION_KEYS"), os.Getenv("TOKEN_ENCRYPTION_KEY_ID")); err != nil {
+		log.Fatal("Invalid token encryption keys: ", err)
+	}
+	if ActiveKeyID() == "" {
+		log.Println("TOKEN_ENCRYPTION_KEYS is not set, provider tokens can't be stored")
+		return
+	}
+	log.Printf("Loaded %d token encryption keys, active key %q\n", len(keys), ActiveKeyID())
+}
+
+// Load parses a key list and makes it the current key ring
+func Load(spec, active string) error {
+	next := map[string][]byte{}
+	first := ""
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return fmt.Errorf("key entries must look like id:base64key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("key %q is not valid base64", id)
+		}
+		if len(key) != 32 {
+			return fmt.Errorf("key %q must be 32 bytes, got %d", id, len(key))
+		}
+		if _, dup := next[id]; dup {
+			return fmt.Errorf("key %q is listed twice", id)
+		}
+		next[id] = key
+		if first == "" {
+			first = id
+		}
+	}
+
+	if active == "" {
+		active = first
+	}
+	if active != "" {
+		if _, ok := next[active]; !ok {
+			return fmt.Errorf("active key %q is not in the key list", active)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	keys = next
+	activeID = active
+	return nil
+}
+
+// ActiveKeyID returns the ID of the key new tokens are sealed with
+func ActiveKeyID() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return activeID
+}
+
+// Encrypt seals a token with a fresh data key wrapped by the active key
+func Encrypt(token string) (string, error) {
+	mu.RLock()
+	id, kek := activeID, keys[activeID]
+	mu.RUnlock()
+	if kek == nil {
+		return "", ErrNoKey
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(kek, dataKey, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(dataKey, []byte(token), []byte(id))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		version,
+		id,
+		base64.RawStdEncoding.EncodeToString(wrappedKey),
+		base64.RawStdEncoding.EncodeToString(sealed),
+	}, ":"), nil
+}
+
+// Decrypt opens a stored token
+// Values that aren't encrypted are tokens saved before encryption and are returned as-is
+func Decrypt(stored string) (string, error) {
+	if !IsEncrypted(stored) {
+		return stored, nil
+	}
+	parts := strings.Split(stored, ":")
+	if len(parts) != 4 {
+		return "", ErrMalformed
+	}
+	id := parts[1]
+
+	mu.RLock()
+	kek := keys[id]
+	mu.RUnlock()
+	if kek == nil {
+		return "", ErrUnknownKey
+	}
+
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	dataKey, err := open(kek, wrappedKey, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	token, err := open(dataKey, sealed, []byte(id))
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+// IsEncrypted reports whether a stored value was produced by Encrypt
+func IsEncrypted(stored string) bool {
+	return strings.HasPrefix(stored, version+":")
+}
+
+// KeyID returns the ID of the key a stored value was sealed with, "" for plaintext values
+func KeyID(stored string) string {
+	if !IsEncrypted(stored) {
+		return ""
+	}
+	parts := strings.Split(stored, ":")
+	if len(parts) != 4 {
+		return ""
+	}
+	return parts[1]
+}
+
+// NeedsRotation reports whether a stored value should be re-encrypted with the active key
+func NeedsRotation(stored string) bool {
+	return stored != "" && KeyID(stored) != ActiveKeyID()
+}
+
+// Helper function to AES-GCM seal a value, the nonce is prepended to the ciphertext
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Helper function to open a value produced by seal
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
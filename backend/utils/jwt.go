@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"os"
 	"time"
 
@@ -41,18 +42,24 @@ func VerifyJWT(tokenString string) (*Claims, error) {
 	/*
 		Verifies a JWT
 		Returns the claims and an error if it fails
+		Tokens issued for anything else (they carry an audience) are rejected
 	*/
 
 	// Parse the JWT with the secret key
 	secret := []byte(os.Getenv("JWT_SECRET"))
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return secret, nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, err
 	}
 
+	// Session tokens never have an audience
+	if claims, ok := token.Claims.(*Claims); ok && len(claims.Audience) > 0 {
+		return nil, errors.New("token is not a session token")
+	}
+
 	// Check if the token is valid
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
 		return claims, nil